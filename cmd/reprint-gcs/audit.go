@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minodisk/reprint/internal/audit"
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	// Reading the audit log needs neither a bucket nor credentials.
+	cfg, err := config.Load(configOptions()...)
+	if err != nil {
+		return err
+	}
+	if cfg.AuditLog == "" {
+		return fmt.Errorf("audit_log is not set (REPRINT_AUDIT_LOG or config file)")
+	}
+	if auditOp != "" && !slices.Contains([]string{audit.OpUpload, audit.OpDelete, audit.OpScheduleDelete}, auditOp) {
+		return fmt.Errorf("invalid --op %q: must be %s, %s or %s", auditOp, audit.OpUpload, audit.OpDelete, audit.OpScheduleDelete)
+	}
+	if auditOutcome != "" && !slices.Contains([]string{audit.OutcomeOK, audit.OutcomeCached, audit.OutcomeNotFound, audit.OutcomeFailed}, auditOutcome) {
+		return fmt.Errorf("invalid --outcome %q: must be %s, %s, %s or %s", auditOutcome, audit.OutcomeOK, audit.OutcomeCached, audit.OutcomeNotFound, audit.OutcomeFailed)
+	}
+
+	filter := audit.Filter{Op: auditOp, Outcome: auditOutcome, Actor: auditActor, Object: auditObject}
+	if auditSince > 0 {
+		filter.Since = time.Now().Add(-auditSince)
+	}
+	records, err := openAuditLog(cfg).Read(filter)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tOP\tOUTCOME\tOBJECT\tSIZE\tACTOR")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			r.Time.Local().Format(time.RFC3339), r.Op, r.Outcome, r.Object, r.Size, r.Actor)
+	}
+	return w.Flush()
+}
+
+// openAuditLog returns the audit log configured in cfg.
+func openAuditLog(cfg *config.Config) *audit.Log {
+	// loadConfig reports an invalid size; reading the log does not need it.
+	maxSize, _ := config.ParseSize(cfg.AuditLogMaxSize)
+	return audit.New(cfg.AuditLog, maxSize)
+}
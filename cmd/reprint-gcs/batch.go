@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// forEachOrdered calls fn for each index in [0, n) with at most concurrency
+// calls running at a time, and passes the results to emit in index order as
+// they become available. It stops at the first error from emit.
+func forEachOrdered[T any](n, concurrency int, fn func(i int) T, emit func(i int, r T) error) error {
+	results := make([]chan T, n)
+	sem := make(chan struct{}, concurrency)
+	for i := range results {
+		results[i] = make(chan T, 1)
+		go func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] <- fn(i)
+		}()
+	}
+
+	for i := range results {
+		if err := emit(i, <-results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLines reads one entry per line, such as file paths or object ids.
+// Blank lines and lines starting with # are ignored.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
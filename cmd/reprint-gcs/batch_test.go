@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEachOrdered(t *testing.T) {
+	tests := []struct {
+		name        string
+		n           int
+		concurrency int
+	}{
+		{name: "sequential", n: 5, concurrency: 1},
+		{name: "concurrent", n: 10, concurrency: 3},
+		{name: "more workers than items", n: 3, concurrency: 8},
+		{name: "no items", n: 0, concurrency: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, peak atomic.Int32
+			fn := func(i int) int {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+				}
+				// Later items finish first, so results arrive out of order.
+				time.Sleep(time.Duration(tt.n-i) * time.Millisecond)
+				return i * 10
+			}
+
+			var got []int
+			err := forEachOrdered(tt.n, tt.concurrency, fn, func(i int, r int) error {
+				if r != i*10 {
+					t.Errorf("emit(%d, %d), want the result of item %d", i, r, i)
+				}
+				got = append(got, i)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("forEachOrdered() error = %v", err)
+			}
+			want := make([]int, tt.n)
+			for i := range want {
+				want[i] = i
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("emitted %v, want %v", got, want)
+			}
+			if p := int(peak.Load()); p > tt.concurrency {
+				t.Errorf("%d calls ran at a time, want at most %d", p, tt.concurrency)
+			}
+		})
+	}
+}
+
+func TestForEachOrdered_StopsAtError(t *testing.T) {
+	errStop := errors.New("stop")
+	var got []int
+	err := forEachOrdered(5, 2, func(i int) int { return i }, func(i int, _ int) error {
+		got = append(got, i)
+		if i == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("forEachOrdered() error = %v, want %v", err, errStop)
+	}
+	if want := []int{0, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("emitted %v, want %v", got, want)
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "empty", input: "", want: nil},
+		{name: "one per line", input: "a.png\nb.png\n", want: []string{"a.png", "b.png"}},
+		{name: "no trailing newline", input: "a.png\nb.png", want: []string{"a.png", "b.png"}},
+		{name: "blank lines", input: "\na.png\n\n   \nb.png\n\n", want: []string{"a.png", "b.png"}},
+		{name: "comments", input: "# screenshots\na.png\n  # skipped\nb.png\n", want: []string{"a.png", "b.png"}},
+		{name: "surrounding spaces", input: "  a.png \t\n", want: []string{"a.png"}},
+		{name: "CRLF", input: "a.png\r\nb.png\r\n", want: []string{"a.png", "b.png"}},
+		{name: "hash inside a line", input: "slide#1.png\n", want: []string{"slide#1.png"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readLines(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("readLines() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("readLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func runCacheClean(cmd *cobra.Command, args []string) error {
+	dir := uploadCacheDir()
+	if dir == "" {
+		return fmt.Errorf("cache directory is unknown")
+	}
+
+	n := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clean upload cache: %w", err)
+	}
+
+	// The uploaded objects are left alone; gc and the bucket lifecycle delete them.
+	fmt.Printf("Removed %d cached uploads from %s\n", n, dir)
+	return nil
+}
+
+// uploadCacheDir returns the directory of the upload cache, or "" if the
+// cache directory is unknown.
+func uploadCacheDir() string {
+	dir := config.CacheDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "objects")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/minodisk/reprint/internal/ledger"
+	"github.com/spf13/cobra"
+)
+
+func runCleanup(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if maxAge < 0 {
+		return fmt.Errorf("--max-age must not be negative")
+	}
+	l := openLedger()
+	if l == nil {
+		return fmt.Errorf("state directory is unknown")
+	}
+
+	entries, err := l.Outstanding()
+	if err != nil {
+		return err
+	}
+
+	type location struct{ bucket, prefix, profile, billingProject string }
+	var order []location
+	groups := make(map[location][]ledger.Entry)
+	for _, e := range orphanedEntries(entries, time.Now(), maxAge) {
+		loc := location{e.Bucket, e.Prefix, e.Profile, e.BillingProject}
+		if _, ok := groups[loc]; !ok {
+			order = append(order, loc)
+		}
+		groups[loc] = append(groups[loc], e)
+	}
+
+	ctx := cmd.Context()
+	failed, total := 0, 0
+	for _, loc := range order {
+		group := groups[loc]
+		total += len(group)
+		if dryRun {
+			for _, e := range group {
+				fmt.Printf("Would delete %s\n", e.ID)
+			}
+			continue
+		}
+
+		c, err := ledgerConfig(cfg, group[0])
+		if err != nil {
+			return err
+		}
+		n, err := cleanupObjects(ctx, c, group)
+		if err != nil {
+			return err
+		}
+		failed += n
+	}
+	if dryRun {
+		return nil
+	}
+
+	if err := l.Compact(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d objects", failed, total)
+	}
+	return nil
+}
+
+// orphanedEntries returns the entries of uploads that are no longer in use.
+// Uploads of runs that are still going may not have been deleted yet. A
+// session spans several deck runs and ends with `session end`, which deletes
+// its uploads, so they are only orphaned once the session has had no uploads
+// for maxAge (never if it is 0). Uploads scheduled for deletion are left to
+// the scheduled delete until it is due.
+func orphanedEntries(entries []ledger.Entry, now time.Time, maxAge time.Duration) []ledger.Entry {
+	lastUpload := make(map[string]time.Time)
+	for _, e := range entries {
+		if e.Session != "" && e.Time.After(lastUpload[e.Session]) {
+			lastUpload[e.Session] = e.Time
+		}
+	}
+
+	var orphaned []ledger.Entry
+	for _, e := range entries {
+		if e.Session != "" && (maxAge == 0 || now.Sub(lastUpload[e.Session]) < maxAge) {
+			continue
+		}
+		if now.Before(e.DeleteAt) || e.Running() {
+			continue
+		}
+		orphaned = append(orphaned, e)
+	}
+	return orphaned
+}
+
+// cleanupObjects deletes the uploads of entries, which share a bucket and
+// prefix, records them as deleted and returns the number of failed deletes.
+func cleanupObjects(ctx context.Context, cfg *config.Config, entries []ledger.Entry) (int, error) {
+	client, err := newClient(ctx, cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	failed := 0
+	var done []string
+	for _, e := range entries {
+		// Never delete a newer object that reused the name.
+		err := client.DeleteGeneration(ctx, e.ID, e.Generation)
+		switch {
+		case err == nil:
+			fmt.Printf("Deleted %s\n", e.ID)
+		case gcs.IsNotFound(err):
+			fmt.Printf("Not found %s\n", e.ID)
+		case errors.Is(err, gcs.ErrGenerationMismatch):
+			fmt.Printf("Skipped %s: replaced by a newer object\n", e.ID)
+		default:
+			fmt.Printf("Failed to delete %s: %v\n", e.ID, err)
+			failed++
+			continue
+		}
+		done = append(done, e.ID)
+	}
+	recordDeletes(cfg, done...)
+	return failed, nil
+}
+
+// ledgerConfig returns the config to delete the upload of a ledger entry
+// with: cfg, or the config of the profile it was uploaded with, for the bucket,
+// client prefix and billing project of the upload.
+func ledgerConfig(cfg *config.Config, e ledger.Entry) (*config.Config, error) {
+	c := *cfg
+	if e.Profile != "" && e.Profile != cfg.Profile {
+		pc, err := config.Load(append(configOptions(), config.WithProfile(e.Profile))...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load profile %q of %s: %w", e.Profile, e.ID, err)
+		}
+		c = *pc
+	}
+	c.Bucket, c.Prefix, c.PrefixTemplate = e.Bucket, e.Prefix, ""
+	c.BillingProject = e.BillingProject
+	return &c, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minodisk/reprint/internal/ledger"
+)
+
+func TestOrphanedEntries(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	const maxAge = 7 * 24 * time.Hour
+	entries := []ledger.Entry{
+		{ID: "run", Time: now.Add(-time.Hour)},
+		{ID: "fresh-session", Session: "fresh", Time: now.Add(-time.Hour)},
+		// An old upload of a session that is still in use.
+		{ID: "active-old", Session: "active", Time: now.Add(-30 * 24 * time.Hour)},
+		{ID: "active-new", Session: "active", Time: now.Add(-24 * time.Hour)},
+		{ID: "stale-1", Session: "stale", Time: now.Add(-9 * 24 * time.Hour)},
+		{ID: "stale-2", Session: "stale", Time: now.Add(-8 * 24 * time.Hour)},
+		{ID: "scheduled", Time: now.Add(-time.Hour), DeleteAt: now.Add(time.Hour)},
+		{ID: "due", Time: now.Add(-time.Hour), DeleteAt: now.Add(-time.Minute)},
+	}
+
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		want   []string
+	}{
+		{name: "stale sessions", maxAge: maxAge, want: []string{"run", "stale-1", "stale-2", "due"}},
+		{name: "sessions kept", maxAge: 0, want: []string{"run", "due"}},
+		{name: "short max age", maxAge: 2 * time.Hour, want: []string{"run", "active-old", "active-new", "stale-1", "stale-2", "due"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range orphanedEntries(entries, now, tt.maxAge) {
+				got = append(got, e.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("orphanedEntries() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/gcs"
+)
+
+// newClient creates a GCS client from the loaded configuration.
+func newClient(ctx context.Context, cfg *config.Config) (client *gcs.Client, err error) {
+	ctx, span := tracer.Start(ctx, "gcs.new_client")
+	defer func() { endSpan(span, err) }()
+
+	opts := []gcs.Option{
+		gcs.WithMetadata(objectMetadata(cfg)),
+		gcs.WithCacheControl(cfg.CacheControl),
+		gcs.WithContentDisposition(cfg.ContentDisposition),
+		gcs.WithStorageClass(cfg.StorageClass),
+		gcs.WithKMSKey(cfg.KMSKey),
+		gcs.WithTransport(cfg.Transport),
+		gcs.WithBillingProject(cfg.BillingProject),
+	}
+
+	if cfg.ChunkSize != "" {
+		size, err := config.ParseSize(cfg.ChunkSize)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, gcs.WithChunkSize(int(size)))
+	}
+	if cfg.ChunkRetryDeadline > 0 {
+		opts = append(opts, gcs.WithChunkRetryDeadline(cfg.ChunkRetryDeadline))
+	}
+	if cfg.Resumable {
+		dir := config.CacheDir()
+		if dir == "" {
+			return nil, fmt.Errorf("resumable uploads need a cache directory, but the home directory is unknown")
+		}
+		opts = append(opts, gcs.WithResumableSessions(filepath.Join(dir, "uploads")))
+	}
+	if cfg.UploadCache {
+		dir := uploadCacheDir()
+		if dir == "" {
+			return nil, fmt.Errorf("the upload cache needs a cache directory, but the home directory is unknown")
+		}
+		// Profiles do not share cached uploads.
+		opts = append(opts, gcs.WithUploadCache(filepath.Join(dir, cfg.Profile)))
+	}
+	if cfg.AuditLog != "" {
+		opts = append(opts, gcs.WithAuditLog(openAuditLog(cfg)))
+	}
+	opts = append(opts, gcs.WithProgress(printProgress), gcs.WithLogger(slog.Default()))
+
+	data, err := cfg.CredentialsData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		opts = append(opts, gcs.WithCredentialsJSON(data))
+	}
+
+	key, err := cfg.EncryptionKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		opts = append(opts, gcs.WithEncryptionKey(key))
+	}
+
+	return gcs.NewClient(ctx, cfg.Bucket, clientPrefix(cfg), cfg.Credentials, opts...)
+}
+
+// printProgress reports the progress of uploads that take more than one
+// chunk on stderr, since stdout is read by deck.
+func printProgress(sent, total int64) {
+	if total < 0 {
+		fmt.Fprintf(os.Stderr, "Uploaded %s\n", formatSize(sent))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Uploaded %s of %s (%d%%)\n", formatSize(sent), formatSize(total), sent*100/max(total, 1))
+}
+
+// formatSize formats a byte size with a binary unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMG"[exp])
+}
+
+// objectMetadata returns the configured custom metadata together with the
+// standard reprint-* metadata, which takes precedence.
+func objectMetadata(cfg *config.Config) map[string]string {
+	md := make(map[string]string, len(cfg.Metadata)+4)
+	for k, v := range cfg.Metadata {
+		md[k] = v
+	}
+
+	vars := config.NewPrefixVars(time.Now(), cfg.Deck, cfg.Profile)
+	md[gcs.MetadataVersion] = version
+	if vars.User != "" {
+		md[gcs.MetadataUser] = vars.User
+	}
+	if vars.Hostname != "" {
+		md[gcs.MetadataHost] = vars.Hostname
+	}
+	if cfg.Deck != "" {
+		md[gcs.MetadataDeck] = cfg.Deck
+	}
+	if cfg.Session != "" {
+		md[gcs.MetadataSession] = cfg.Session
+	}
+	return md
+}
+
+// clientPrefix returns the prefix the GCS client prepends to object ids.
+// A prefix template may render differently between upload and delete (e.g.,
+// {{.Date}} across midnight), so with a template the rendered prefix becomes
+// part of the object id instead.
+func clientPrefix(cfg *config.Config) string {
+	if cfg.PrefixTemplate != "" {
+		return ""
+	}
+	return cfg.Prefix
+}
+
+// newObjectID returns a new object id for an upload. Uploads of a session
+// are grouped under the session id.
+func newObjectID(cfg *config.Config) (string, error) {
+	id := uuid.New().String()
+	if cfg.Session != "" {
+		id = cfg.Session + "/" + id
+	}
+	if cfg.PrefixTemplate != "" {
+		prefix, err := cfg.RenderedPrefix()
+		if err != nil {
+			return "", err
+		}
+		return prefix + id, nil
+	}
+	return id, nil
+}
@@ -1,29 +1,211 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
 
 	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/spf13/cobra"
 )
 
-func loadConfig() (*config.Config, error) {
-	cfg, err := config.Load(
+// configOptions returns the config options built from CLI flags.
+// Malformed --metadata pairs are reported by loadConfig.
+func configOptions() []config.Option {
+	md, _ := config.ParseKeyValues(metadata)
+	return []config.Option{
 		config.WithAppName(appName),
+		config.WithConfigFile(configFile),
+		config.WithProfile(profile),
 		config.WithBucket(bucket),
 		config.WithPrefix(prefix),
 		config.WithCredentials(credentials),
-	)
+		config.WithDeck(deck),
+		config.WithMetadata(md),
+		config.WithLogLevel(logLevel),
+		config.WithVerbose(verbose),
+		config.WithLogFormat(logFormat),
+	}
+}
+
+func loadConfig(ctx context.Context) (cfg *config.Config, err error) {
+	_, span := tracer.Start(ctx, "config.load")
+	defer func() { endSpan(span, err) }()
+
+	if _, err := config.ParseKeyValues(metadata); err != nil {
+		return nil, fmt.Errorf("--metadata: %w", err)
+	}
+
+	cfg, err = config.Load(configOptions()...)
 	if err != nil {
 		return nil, err
 	}
+	if _, err := config.ParseLogLevel(cfg.LogLevel); err != nil {
+		return nil, fmt.Errorf("%w (from %s)", err, cfg.Source("log_level"))
+	}
+	if err := config.ValidateLogFormat(cfg.LogFormat); err != nil {
+		return nil, fmt.Errorf("%w (from %s)", err, cfg.Source("log_format"))
+	}
+	setupLogging(cfg.LogLevel, cfg.LogFormat)
+	slog.Debug("loaded config", "files", cfg.Files, "profile", cfg.Profile, "bucket", cfg.Bucket, "prefix", cfg.Prefix)
 
 	// Validate required fields
 	if cfg.Bucket == "" {
 		return nil, fmt.Errorf("bucket is required (--bucket, REPRINT_BUCKET, or config file)")
 	}
-	if cfg.Credentials == "" {
-		return nil, fmt.Errorf("credentials is required (--credentials, REPRINT_CREDENTIALS, config file, or place at %s)", config.DefaultCredentialsPath(appName))
+	if err := gcs.ValidateBucketName(cfg.Bucket); err != nil {
+		return nil, fmt.Errorf("%w (from %s)", err, cfg.Source("bucket"))
+	}
+	if err := gcs.ValidateStorageClass(cfg.StorageClass); err != nil {
+		return nil, fmt.Errorf("%w (from %s)", err, cfg.Source("storage_class"))
+	}
+	if err := gcs.ValidateKMSKeyName(cfg.KMSKey); err != nil {
+		return nil, fmt.Errorf("%w (from %s)", err, cfg.Source("kms_key"))
+	}
+	if cfg.KMSKey != "" && cfg.HasEncryptionKey() {
+		return nil, fmt.Errorf("kms_key (from %s) cannot be combined with encryption_key_file or encryption_key_command", cfg.Source("kms_key"))
+	}
+	if err := gcs.ValidateTransport(cfg.Transport); err != nil {
+		return nil, fmt.Errorf("%w (from %s)", err, cfg.Source("transport"))
+	}
+	if cfg.ChunkSize != "" {
+		if _, err := config.ParseSize(cfg.ChunkSize); err != nil {
+			return nil, fmt.Errorf("chunk_size: %w (from %s)", err, cfg.Source("chunk_size"))
+		}
+	}
+	if cfg.ChunkRetryDeadline < 0 {
+		return nil, fmt.Errorf("chunk_retry_deadline must not be negative (from %s)", cfg.Source("chunk_retry_deadline"))
+	}
+	if cfg.AuditLogMaxSize != "" {
+		if _, err := config.ParseSize(cfg.AuditLogMaxSize); err != nil {
+			return nil, fmt.Errorf("audit_log_max_size: %w (from %s)", err, cfg.Source("audit_log_max_size"))
+		}
+	}
+	if err := config.ValidateSession(cfg.Session); err != nil {
+		return nil, fmt.Errorf("%w (from %s)", err, cfg.Source("session"))
+	}
+	// Check the rendered prefix with a placeholder for the generated object id.
+	if err := gcs.ValidateObjectName(cfg.Prefix + "x"); err != nil {
+		return nil, fmt.Errorf("invalid prefix: %w (from %s)", err, cfg.Source("prefix"))
+	}
+	if !cfg.HasCredentials() {
+		return nil, fmt.Errorf("credentials is required (--credentials, REPRINT_CREDENTIALS, REPRINT_CREDENTIALS_JSON, credentials_command, config file, or place at %s)", config.DefaultCredentialsPath(appName))
 	}
 
 	return cfg, nil
 }
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(configOptions()...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Config files:")
+	if len(cfg.Files) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, f := range cfg.Files {
+		fmt.Printf("  %s\n", f)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	if cfg.Profile != "" {
+		fmt.Fprintf(w, "profile\t%s\t%s\n", cfg.Profile, cfg.Source("profile"))
+	}
+	for _, key := range config.Keys() {
+		value, _ := cfg.Value(key)
+		if value == "" {
+			value = "(not set)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, value, cfg.Source(key))
+	}
+	return w.Flush()
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	f, err := openEditableConfig()
+	if err != nil {
+		return err
+	}
+	value, ok := f.Get(profile, args[0])
+	if !ok {
+		return fmt.Errorf("%s is not set in %s", args[0], f.Path())
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// gcsValidators check the values of config set for settings with a GCS
+// format. Other settings are checked by config.File.Set.
+var gcsValidators = map[string]func(string) error{
+	"bucket":        gcs.ValidateBucketName,
+	"storage_class": gcs.ValidateStorageClass,
+	"kms_key":       gcs.ValidateKMSKeyName,
+	"transport":     gcs.ValidateTransport,
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	f, err := openEditableConfig()
+	if err != nil {
+		return err
+	}
+	if configProject && slices.Contains(config.UserOnlyKeys, args[0]) {
+		return fmt.Errorf("%s is not allowed in a project config file; set it in the user config file or %s", args[0], config.EnvName(args[0]))
+	}
+	if validate, ok := gcsValidators[args[0]]; ok {
+		if err := validate(args[1]); err != nil {
+			return err
+		}
+	}
+	if err := f.Set(profile, args[0], args[1]); err != nil {
+		return err
+	}
+	return f.Save()
+}
+
+func runConfigUnset(cmd *cobra.Command, args []string) error {
+	f, err := openEditableConfig()
+	if err != nil {
+		return err
+	}
+	if !f.Unset(profile, args[0]) {
+		return fmt.Errorf("%s is not set in %s", args[0], f.Path())
+	}
+	return f.Save()
+}
+
+// editableConfigPath returns the config file targeted by config subcommands.
+func editableConfigPath() (string, error) {
+	if !configProject {
+		path := config.EditablePath(configFile)
+		if path == "" {
+			return "", fmt.Errorf("cannot determine config file path (use --config)")
+		}
+		return path, nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if path := config.FindProjectConfig(wd); path != "" {
+		return path, nil
+	}
+	return filepath.Join(wd, config.ProjectConfigFilename), nil
+}
+
+func openEditableConfig() (*config.File, error) {
+	path, err := editableConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return config.OpenFile(path)
+}
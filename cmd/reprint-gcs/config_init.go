@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/spf13/cobra"
+)
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	path, err := editableConfigPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil && !configForce {
+		return fmt.Errorf("config file %s already exists (use --force to overwrite)", path)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	fmt.Printf("Creating %s\n", path)
+	if profile != "" {
+		fmt.Printf("Settings are written to profile %q\n", profile)
+	}
+	fmt.Println()
+
+	bucketName, err := prompt(in, "GCS bucket name", bucket)
+	if err != nil {
+		return err
+	}
+	if bucketName == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	if err := gcs.ValidateBucketName(bucketName); err != nil {
+		return err
+	}
+	objectPrefix, err := prompt(in, "Object prefix (optional)", prefix)
+	if err != nil {
+		return err
+	}
+	defaultCreds := credentials
+	if defaultCreds == "" {
+		defaultCreds = config.DefaultCredentialsPath(appName)
+	}
+	credsPath, err := prompt(in, "Service account key file", defaultCreds)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	if !checkInitBucket(bucketName, objectPrefix, credsPath) {
+		ok, err := confirm(in, "Save the configuration anyway?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	f, err := config.OpenFile(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Set(profile, "bucket", bucketName); err != nil {
+		return err
+	}
+	if objectPrefix != "" {
+		if err := f.Set(profile, "prefix", objectPrefix); err != nil {
+			return err
+		}
+	}
+	// The default credentials path is picked up automatically.
+	if credsPath != "" && credsPath != config.DefaultCredentialsPath(appName) {
+		if err := f.Set(profile, "credentials", credsPath); err != nil {
+			return err
+		}
+	}
+	if err := f.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s\n", path)
+	fmt.Println("Run `reprint-gcs doctor` to verify upload and delete permissions.")
+	return nil
+}
+
+// checkInitBucket reports whether the bucket is accessible with the given credentials.
+func checkInitBucket(bucketName, objectPrefix, credsPath string) bool {
+	fmt.Print("[GCS] Checking bucket access... ")
+	if _, err := os.Stat(credsPath); err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		return false
+	}
+
+	ctx := context.Background()
+	client, err := gcs.NewClient(ctx, bucketName, objectPrefix, credsPath)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		return false
+	}
+	defer client.Close()
+
+	if err := client.CheckBucket(ctx); err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		return false
+	}
+	fmt.Println("OK")
+	return true
+}
+
+// prompt asks for a value on stdin, returning def when the answer is empty.
+func prompt(in *bufio.Reader, label, def string) (string, error) {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", label, def)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+	line, err := in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if errors.Is(err, io.EOF) {
+		fmt.Println()
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no.
+func confirm(in *bufio.Reader, question string) (bool, error) {
+	answer, err := prompt(in, question+" [y/N]", "")
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/daemon"
+	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if idleTimeout <= 0 {
+		return fmt.Errorf("--idle-timeout must be positive")
+	}
+	path := daemonSocketPath()
+	if path == "" {
+		return fmt.Errorf("the daemon needs a socket directory, but the home directory is unknown")
+	}
+
+	// The client outlives any single request, so it is not tied to the
+	// signal context.
+	client, err := newClient(cmd.Context(), cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	l, err := listenDaemon(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fingerprint := daemonFingerprint(cfg)
+	srv := &daemon.Server{IdleTimeout: idleTimeout}
+	srv.Handler = func(ctx context.Context, req *daemon.Request) *daemon.Response {
+		if req.Fingerprint != fingerprint {
+			slog.DebugContext(ctx, "rejected request for a different configuration", "op", req.Op)
+			return &daemon.Response{Mismatch: true}
+		}
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(req.Trace))
+		// The caller's log level applies to its requests if it is more
+		// verbose, but records keep the daemon's format.
+		if lvl, err := config.ParseLogLevel(req.LogLevel); req.LogLevel != "" && err == nil {
+			ctx = withLogLevel(ctx, lvl)
+		}
+		slog.DebugContext(ctx, "handling request", "op", req.Op, "ids", len(req.IDs), "bytes", len(req.Data))
+		switch req.Op {
+		case daemon.OpPing:
+			return &daemon.Response{}
+		case daemon.OpUpload:
+			// The deck and session differ between callers, so the metadata
+			// is built for each request.
+			reqCfg := *cfg
+			reqCfg.Deck, reqCfg.Session = req.Deck, req.Session
+			res, err := client.Upload(ctx, req.ID, bytes.NewReader(req.Data), req.MIME, gcs.WithUploadMetadata(objectMetadata(&reqCfg)))
+			if err != nil {
+				return daemon.ErrorResponse(err)
+			}
+			return &daemon.Response{Upload: res}
+		case daemon.OpDelete:
+			if req.After > 0 {
+				return &daemon.Response{Deletes: scheduleDeletes(ctx, cfg, srv, client, req)}
+			}
+			return &daemon.Response{Deletes: deleteObjects(ctx, client, req.IDs, req.Generation, req.Concurrency)}
+		}
+		return daemon.ErrorResponse(fmt.Errorf("unknown operation %q", req.Op))
+	}
+
+	// stdout is kept free like for the other commands.
+	fmt.Fprintf(os.Stderr, "Listening on %s (idle timeout %s)\n", path, idleTimeout)
+	if err := srv.Serve(ctx, l); err != nil {
+		return err
+	}
+	if n := srv.Dropped(); n > 0 {
+		if ok, err := client.DeletesByCustomTime(context.Background()); err == nil && !ok {
+			fmt.Fprintf(os.Stderr, "Left %d scheduled deletions undone; run reprint-gcs cleanup once they are due\n", n)
+		} else {
+			fmt.Fprintf(os.Stderr, "Left %d scheduled deletions to the bucket lifecycle\n", n)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Daemon stopped")
+	return nil
+}
+
+// scheduleDeletes schedules the deletes of a request on the daemon's timer.
+// The objects also get their custom time set, so that a bucket lifecycle
+// rule still deletes them if the daemon stops before the timer fires.
+// Without such a rule, they are left to cleanup, which the ledger allows.
+func scheduleDeletes(ctx context.Context, cfg *config.Config, srv *daemon.Server, client *gcs.Client, req *daemon.Request) []daemon.DeleteResult {
+	if ok, err := client.DeletesByCustomTime(ctx); err == nil && !ok {
+		slog.WarnContext(ctx, "the bucket lifecycle has no rule on daysSinceCustomTime, so deletes still pending when the daemon stops are left to cleanup")
+	}
+	at := time.Now().Add(req.After)
+	results := make([]daemon.DeleteResult, len(req.IDs))
+	var scheduled []string
+	forEachOrdered(len(req.IDs), max(req.Concurrency, 1), func(i int) daemon.DeleteResult {
+		return scheduleObject(ctx, client, req.IDs[i], req.Generation, at)
+	}, func(i int, r daemon.DeleteResult) error {
+		results[i] = r
+		if r.Error == "" {
+			scheduled = append(scheduled, r.ID)
+		}
+		return nil
+	})
+
+	if len(scheduled) > 0 {
+		srv.After(req.After, func() {
+			var done []string
+			for _, r := range deleteObjects(context.Background(), client, scheduled, req.Generation, req.Concurrency) {
+				if r.Error != "" && !r.NotFound {
+					fmt.Fprintf(os.Stderr, "Failed to delete %s: %s\n", r.ID, r.Error)
+					continue
+				}
+				done = append(done, r.ID)
+			}
+			recordDeletes(cfg, done...)
+		})
+	}
+	return results
+}
+
+// daemonSocketPath returns the path of the daemon's Unix socket, in
+// $XDG_RUNTIME_DIR if set and the cache directory otherwise. It returns ""
+// if neither is known.
+func daemonSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "reprint", "daemon.sock")
+	}
+	if dir := config.CacheDir(); dir != "" {
+		return filepath.Join(dir, "daemon.sock")
+	}
+	return ""
+}
+
+// listenDaemon listens on the socket at path, replacing a socket left behind
+// by a daemon that did not shut down cleanly.
+func listenDaemon(path string) (net.Listener, error) {
+	// Only the user may connect, since the daemon acts with their credentials.
+	// MkdirAll keeps the mode of an existing directory, so it is tightened too.
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to restrict socket directory: %w", err)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already running on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	// Windows has no permission bits for sockets; the directory ACL applies.
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(path, 0600); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("failed to restrict socket: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// daemonFingerprint identifies the configuration a daemon serves. The version
+// is part of it, so a daemon left running across an upgrade is not used.
+// Per-invocation settings are sent with each request instead.
+func daemonFingerprint(cfg *config.Config) string {
+	return version + "/" + cfg.Fingerprint()
+}
+
+// callDaemon forwards req to a running daemon. It returns false if there is
+// no daemon serving cfg, in which case the caller handles the request itself.
+func callDaemon(ctx context.Context, cfg *config.Config, req *daemon.Request) (*daemon.Response, bool, error) {
+	path := daemonSocketPath()
+	if path == "" {
+		return nil, false, nil
+	}
+	req.Fingerprint = daemonFingerprint(cfg)
+	req.Deck, req.Session, req.LogLevel = cfg.Deck, cfg.Session, cfg.LogLevel
+	req.Trace = propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(req.Trace))
+	resp, err := daemon.Call(ctx, path, req)
+	switch {
+	case errors.Is(err, daemon.ErrNotRunning):
+		slog.DebugContext(ctx, "no daemon running", "socket", path)
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	case resp.Mismatch:
+		slog.DebugContext(ctx, "daemon serves a different configuration", "socket", path)
+		return nil, false, nil
+	}
+	slog.DebugContext(ctx, "forwarded to daemon", "socket", path, "op", req.Op)
+	if err := resp.Err(); err != nil {
+		return nil, true, err
+	}
+	return resp, true, nil
+}
+
+// deleteObjects deletes objects concurrently and returns the outcome for each.
+func deleteObjects(ctx context.Context, client *gcs.Client, ids []string, generation int64, concurrency int) []daemon.DeleteResult {
+	results := make([]daemon.DeleteResult, len(ids))
+	forEachOrdered(len(ids), max(concurrency, 1), func(i int) daemon.DeleteResult {
+		return deleteObject(ctx, client, ids[i], generation)
+	}, func(i int, r daemon.DeleteResult) error {
+		results[i] = r
+		return nil
+	})
+	return results
+}
+
+// scheduleObject schedules the deletion of an object at the given time by
+// setting its custom time, and returns the outcome.
+func scheduleObject(ctx context.Context, client *gcs.Client, id string, generation int64, at time.Time) daemon.DeleteResult {
+	r := daemon.DeleteResult{ID: id}
+	if err := client.ScheduleDelete(ctx, id, generation, at); err != nil {
+		r.NotFound = gcs.IsNotFound(err)
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// deleteObject deletes an object and returns the outcome.
+func deleteObject(ctx context.Context, client *gcs.Client, id string, generation int64) daemon.DeleteResult {
+	r := daemon.DeleteResult{ID: id}
+	if err := client.DeleteGeneration(ctx, id, generation); err != nil {
+		r.NotFound = gcs.IsNotFound(err)
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestListenDaemon_Permissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no permission bits for sockets")
+	}
+	// An existing directory that others may enter.
+	dir := filepath.Join(t.TempDir(), "reprint")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "daemon.sock")
+
+	l, err := listenDaemon(path)
+	if err != nil {
+		t.Fatalf("listenDaemon() error = %v", err)
+	}
+	defer l.Close()
+
+	for p, want := range map[string]os.FileMode{dir: 0700, path: 0600} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fi.Mode().Perm(); got != want {
+			t.Errorf("mode of %s = %v, want %v", p, got, want)
+		}
+	}
+}
@@ -1,34 +1,162 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"os"
+	"slices"
+	"time"
 
-	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/minodisk/reprint/internal/daemon"
 	"github.com/spf13/cobra"
 )
 
 func runDelete(cmd *cobra.Command, args []string) error {
-	cfg, err := loadConfig()
+	cfg, err := loadConfig(cmd.Context())
 	if err != nil {
 		return err
 	}
 
-	// Get object ID from flag or environment variable
-	if objectID == "" {
-		objectID = os.Getenv("DECK_DELETE_ID")
+	ids, err := deleteIDs()
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("object-id is required (--object-id, --from-file, or DECK_DELETE_ID)")
+	}
+	if generation != 0 && len(ids) > 1 {
+		return fmt.Errorf("--generation can only be used with a single object id")
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
 	}
-	if objectID == "" {
-		return fmt.Errorf("object-id is required (--object-id or DECK_DELETE_ID)")
+	if deleteAfter < 0 {
+		return fmt.Errorf("--after must not be negative")
 	}
 
-	ctx := context.Background()
-	client, err := gcs.NewClient(ctx, cfg.Bucket, cfg.Prefix, cfg.Credentials)
+	// A single id is deck's delete hook, which expects no output on success.
+	batch := len(ids) > 1 || fromFile != ""
+	action := "delete"
+	if deleteAfter > 0 {
+		action = "schedule deletion of"
+	}
+
+	// Scheduled objects are only recorded as deleted once the delete runs.
+	at := time.Now().Add(deleteAfter)
+	failed := 0
+	var done, scheduled []string
+	report := func(r daemon.DeleteResult) {
+		if r.Error == "" && deleteAfter > 0 {
+			scheduled = append(scheduled, r.ID)
+		} else if r.Error == "" || r.NotFound {
+			done = append(done, r.ID)
+		}
+		switch {
+		case r.Error == "":
+			if batch && deleteAfter > 0 {
+				fmt.Printf("Scheduled deletion of %s in %s\n", r.ID, deleteAfter)
+			} else if batch {
+				fmt.Printf("Deleted %s\n", r.ID)
+			}
+		case r.NotFound:
+			// Already gone, which is what the caller wants.
+			if batch {
+				fmt.Printf("Not found %s\n", r.ID)
+			} else {
+				fmt.Fprintf(os.Stderr, "Not found %s\n", r.ID)
+			}
+		default:
+			failed++
+			if batch {
+				fmt.Printf("Failed to %s %s: %s\n", action, r.ID, r.Error)
+			} else {
+				fmt.Fprintf(os.Stderr, "Failed to %s %s: %s\n", action, r.ID, r.Error)
+			}
+		}
+	}
+
+	ctx := cmd.Context()
+	resp, forwarded, err := callDaemon(ctx, cfg, &daemon.Request{
+		Op:          daemon.OpDelete,
+		IDs:         ids,
+		Generation:  generation,
+		Concurrency: concurrency,
+		After:       deleteAfter,
+	})
 	if err != nil {
 		return err
 	}
-	defer client.Close()
+	if forwarded {
+		for _, r := range resp.Deletes {
+			report(r)
+		}
+	} else {
+		client, err := newClient(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		// Without the daemon's timer, scheduled deletes rely on the bucket
+		// lifecycle. If it cannot be read, they are scheduled regardless.
+		if deleteAfter > 0 {
+			if ok, err := client.DeletesByCustomTime(ctx); err == nil && !ok {
+				return fmt.Errorf("--after needs a bucket lifecycle rule that deletes objects by daysSinceCustomTime, or a running daemon (reprint-gcs daemon)")
+			}
+		}
+
+		err = forEachOrdered(len(ids), concurrency, func(i int) daemon.DeleteResult {
+			if deleteAfter > 0 {
+				return scheduleObject(ctx, client, ids[i], generation, at)
+			}
+			return deleteObject(ctx, client, ids[i], generation)
+		}, func(_ int, r daemon.DeleteResult) error {
+			report(r)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	recordDeletes(cfg, done...)
+	recordSchedules(cfg, at, scheduled...)
+
+	if failed > 0 {
+		return fmt.Errorf("failed to %s %d of %d objects", action, failed, len(ids))
+	}
+	return nil
+}
+
+// deleteIDs returns the object ids to delete from --object-id, --from-file
+// ("-" for stdin) or DECK_DELETE_ID, without duplicates.
+func deleteIDs() ([]string, error) {
+	ids := slices.Clone(objectIDs)
+	if fromFile != "" {
+		r := os.Stdin
+		if fromFile != "-" {
+			f, err := os.Open(fromFile)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		lines, err := readLines(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read object ids: %w", err)
+		}
+		ids = append(ids, lines...)
+	}
+	if len(ids) == 0 {
+		if id := os.Getenv("DECK_DELETE_ID"); id != "" {
+			ids = append(ids, id)
+		}
+	}
 
-	return client.Delete(ctx, objectID)
+	seen := make(map[string]bool, len(ids))
+	return slices.DeleteFunc(ids, func(id string) bool {
+		dup := seen[id]
+		seen[id] = true
+		return dup
+	}), nil
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minodisk/reprint/internal/ledger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// fakeGCS is a GCS JSON API server for the objects of "test-bucket" that
+// records deletes and scheduled deletes. Objects in missing do not exist,
+// and deleting objects in denied is forbidden. With lifecycle, the bucket
+// deletes objects by their custom time.
+type fakeGCS struct {
+	mu        sync.Mutex
+	deleted   []string
+	scheduled []string
+	missing   map[string]bool
+	denied    map[string]bool
+	lifecycle bool
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/test-bucket" {
+		bucket := `{"name":"test-bucket"}`
+		if f.lifecycle {
+			bucket = `{"name":"test-bucket","lifecycle":{"rule":[{"action":{"type":"Delete"},"condition":{"daysSinceCustomTime":1}}]}}`
+		}
+		io.WriteString(w, bucket)
+		return
+	}
+
+	id, ok := strings.CutPrefix(r.URL.Path, "/storage/v1/b/test-bucket/o/")
+	switch {
+	case !ok:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+	case f.missing[id]:
+		http.Error(w, `{"error":{"code":404,"message":"No such object"}}`, http.StatusNotFound)
+	case f.denied[id]:
+		http.Error(w, `{"error":{"code":403,"message":"Access denied"}}`, http.StatusForbidden)
+	case r.Method == http.MethodDelete:
+		f.deleted = append(f.deleted, id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPatch:
+		f.scheduled = append(f.scheduled, id)
+		fmt.Fprintf(w, `{"name":%q,"bucket":"test-bucket","generation":"1"}`, id)
+	default:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+	}
+}
+
+// Deleted returns the deleted object ids in sorted order.
+func (f *fakeGCS) Deleted() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Sorted(slices.Values(f.deleted))
+}
+
+// Scheduled returns the ids of objects scheduled for deletion in sorted order.
+func (f *fakeGCS) Scheduled() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Sorted(slices.Values(f.scheduled))
+}
+
+// setupCommand points the config, state and daemon socket at a temporary
+// directory and the GCS client at a fake server, parses args for cmd, and
+// resets the flags afterwards.
+func setupCommand(t *testing.T, cmd *cobra.Command, args ...string) *fakeGCS {
+	t.Helper()
+	dir := t.TempDir()
+	for _, env := range []string{"XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_STATE_HOME", "XDG_RUNTIME_DIR"} {
+		t.Setenv(env, dir)
+	}
+	credentials := filepath.Join(dir, "credentials.json")
+	key := `{"type":"service_account","client_email":"sa@example.iam.gserviceaccount.com","private_key":"KEY"}`
+	if err := os.WriteFile(credentials, []byte(key), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REPRINT_BUCKET", "test-bucket")
+	t.Setenv("REPRINT_CREDENTIALS", credentials)
+
+	f := &fakeGCS{missing: make(map[string]bool), denied: make(map[string]bool)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(srv.URL, "http://"))
+
+	t.Cleanup(func() { resetFlags(cmd) })
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	cmd.SetContext(context.Background())
+	return f
+}
+
+// resetFlags sets the flags of cmd back to their defaults.
+func resetFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			v.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+}
+
+func TestDelete_PrefixTemplateWithoutDeck(t *testing.T) {
+	t.Setenv("REPRINT_PREFIX", "deck/{{.Deck}}/")
+	f := setupCommand(t, deleteCmd, "--object-id", "deck/intro/abc")
+
+	if err := runDelete(deleteCmd, nil); err != nil {
+		t.Fatalf("runDelete() error = %v", err)
+	}
+	if got := f.Deleted(); !slices.Equal(got, []string{"deck/intro/abc"}) {
+		t.Errorf("deleted = %v, want the id as is", got)
+	}
+}
+
+func TestDeleteIDs(t *testing.T) {
+	tests := []struct {
+		name      string
+		objectIDs []string
+		file      string // content of --from-file, if set
+		env       string // DECK_DELETE_ID
+		want      []string
+	}{
+		{name: "none", want: nil},
+		{name: "object ids", objectIDs: []string{"a", "b"}, want: []string{"a", "b"}},
+		{name: "duplicate object ids", objectIDs: []string{"a", "b", "a", "b"}, want: []string{"a", "b"}},
+		{name: "from file", file: "# uploaded\na\n\nb\n", want: []string{"a", "b"}},
+		{name: "object ids and file", objectIDs: []string{"a", "b"}, file: "b\nc\n", want: []string{"a", "b", "c"}},
+		{name: "environment", env: "a", want: []string{"a"}},
+		{name: "object ids over environment", objectIDs: []string{"b"}, env: "a", want: []string{"b"}},
+		{name: "file over environment", file: "b\n", env: "a", want: []string{"b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { objectIDs, fromFile = nil, "" })
+			objectIDs, fromFile = tt.objectIDs, ""
+			if tt.file != "" {
+				fromFile = filepath.Join(t.TempDir(), "ids.txt")
+				if err := os.WriteFile(fromFile, []byte(tt.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("DECK_DELETE_ID", tt.env)
+
+			got, err := deleteIDs()
+			if err != nil {
+				t.Fatalf("deleteIDs() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("deleteIDs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []string
+		missing []string
+		denied  []string
+		want    []string // deleted ids
+		wantErr string
+	}{
+		{
+			name: "duplicates are deleted once",
+			ids:  []string{"a", "b", "a"},
+			want: []string{"a", "b"},
+		},
+		{
+			name:    "not found is not an error",
+			ids:     []string{"a", "gone"},
+			missing: []string{"gone"},
+			want:    []string{"a"},
+		},
+		{
+			name:    "failures fail the command",
+			ids:     []string{"a", "gone", "locked", "b"},
+			missing: []string{"gone"},
+			denied:  []string{"locked"},
+			want:    []string{"a", "b"},
+			wantErr: "failed to delete 1 of 4 objects",
+		},
+		{
+			name:    "single failure",
+			ids:     []string{"locked"},
+			denied:  []string{"locked"},
+			wantErr: "failed to delete 1 of 1 objects",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []string
+			for _, id := range tt.ids {
+				args = append(args, "--object-id", id)
+			}
+			f := setupCommand(t, deleteCmd, args...)
+			for _, id := range tt.missing {
+				f.missing[id] = true
+			}
+			for _, id := range tt.denied {
+				f.denied[id] = true
+			}
+
+			err := runDelete(deleteCmd, nil)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("runDelete() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("runDelete() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := f.Deleted(); !slices.Equal(got, tt.want) {
+				t.Errorf("deleted = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelete_After(t *testing.T) {
+	f := setupCommand(t, deleteCmd, "--object-id", "a", "--object-id", "gone", "--after", "10m")
+	f.missing["gone"] = true
+	l := openLedger()
+	uploads := []ledger.Entry{
+		{Op: ledger.OpUpload, Bucket: "test-bucket", ID: "a", Time: time.Now()},
+		{Op: ledger.OpUpload, Bucket: "test-bucket", ID: "gone", Time: time.Now()},
+	}
+	if err := l.Append(uploads...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a lifecycle rule or a daemon, nothing would delete the objects.
+	if err := runDelete(deleteCmd, nil); err == nil || !strings.Contains(err.Error(), "daysSinceCustomTime") {
+		t.Fatalf("runDelete() without a lifecycle rule error = %v, want it to need one", err)
+	}
+
+	f.mu.Lock()
+	f.lifecycle = true
+	f.mu.Unlock()
+	start := time.Now()
+	if err := runDelete(deleteCmd, nil); err != nil {
+		t.Fatalf("runDelete() error = %v", err)
+	}
+	if deleted, scheduled := f.Deleted(), f.Scheduled(); len(deleted) != 0 || !slices.Equal(scheduled, []string{"a"}) {
+		t.Errorf("deleted %q and scheduled %q, want only a scheduled", deleted, scheduled)
+	}
+
+	// The scheduled object stays outstanding, in case its delete never runs.
+	entries, err := l.Outstanding()
+	if err != nil {
+		t.Fatalf("Outstanding() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != "a" || entries[0].DeleteAt.Before(start.Add(10*time.Minute)) {
+		t.Errorf("outstanding = %+v, want a scheduled in 10m", entries)
+	}
+}
@@ -2,21 +2,26 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/google/uuid"
 	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/daemon"
 	"github.com/minodisk/reprint/internal/gcs"
 	"github.com/spf13/cobra"
 )
 
+// doctorDeck is the deck name used to render prefix templates when none is set.
+const doctorDeck = "reprint-doctor"
+
 func runDoctor(cmd *cobra.Command, args []string) error {
 	fmt.Println("Checking reprint-gcs configuration...")
 	fmt.Println()
 
-	ctx := context.Background()
+	ctx := cmd.Context()
 	allOK := true
 
 	cfg, ok := checkConfig()
@@ -24,8 +29,12 @@ func runDoctor(cmd *cobra.Command, args []string) error {
 		allOK = false
 	}
 
+	if cfg != nil && !checkDaemon(ctx, cfg) {
+		allOK = false
+	}
+
 	var client *gcs.Client
-	if cfg != nil && cfg.Bucket != "" && cfg.Credentials != "" {
+	if cfg != nil && cfg.Bucket != "" && cfg.HasCredentials() {
 		var ok bool
 		client, ok = checkGCSConnection(ctx, cfg)
 		if !ok {
@@ -37,15 +46,21 @@ func runDoctor(cmd *cobra.Command, args []string) error {
 	}
 
 	if client != nil {
-		if !checkBucketAccess(ctx, client) {
+		if !checkBucketAccess(ctx, client, cfg) {
 			allOK = false
 		}
 
-		objectID, ok := checkUploadPermission(ctx, client)
+		objectID, ok := checkUploadPermission(ctx, client, cfg)
 		if !ok {
 			allOK = false
 		}
 
+		if objectID != "" && cfg.KMSKey != "" {
+			if !checkKMSKey(ctx, client, cfg, objectID) {
+				allOK = false
+			}
+		}
+
 		if objectID != "" {
 			if !checkDeletePermission(ctx, client, objectID) {
 				allOK = false
@@ -67,51 +82,124 @@ func checkConfig() (*config.Config, bool) {
 	allOK := true
 
 	fmt.Print("[Config] Loading configuration... ")
-	cfg, err := config.Load(
-		config.WithAppName(appName),
-		config.WithBucket(bucket),
-		config.WithPrefix(prefix),
-		config.WithCredentials(credentials),
-	)
+	// deck passes the deck name on each run, so a placeholder renders prefix
+	// templates that use it.
+	cfg, err := config.Load(append(configOptions(), config.WithDefaultDeck(doctorDeck))...)
 	if err != nil {
 		fmt.Printf("ERROR: %v\n", err)
 		return nil, false
 	}
 	fmt.Println("OK")
 
+	fmt.Print("[Config] Config files loaded... ")
+	if len(cfg.Files) == 0 {
+		fmt.Println("(none)")
+	} else {
+		fmt.Println("OK")
+		for _, f := range cfg.Files {
+			fmt.Printf("  - %s\n", f)
+		}
+	}
+
+	fmt.Print("[Config] Profile selected... ")
+	if cfg.Profile == "" {
+		fmt.Println("(not set)")
+	} else {
+		fmt.Printf("OK (%s)\n", cfg.Profile)
+	}
+
 	fmt.Print("[Config] Bucket configured... ")
 	if cfg.Bucket == "" {
 		fmt.Println("ERROR: bucket is not configured")
-		fmt.Println("  Set via: --bucket, REPRINT_BUCKET, or ~/.config/reprint/config.yaml")
+		fmt.Println("  Set via: --bucket, REPRINT_BUCKET, .reprint.yaml, or ~/.config/reprint/config.yaml")
+		allOK = false
+	} else if err := gcs.ValidateBucketName(cfg.Bucket); err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		fmt.Printf("  Set in: %s\n", cfg.Source("bucket"))
 		allOK = false
 	} else {
 		fmt.Printf("OK (%s)\n", cfg.Bucket)
 	}
 
 	fmt.Print("[Config] Prefix configured... ")
-	if cfg.Prefix == "" {
+	if _, err := cfg.RenderedPrefix(); err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		allOK = false
+	} else if cfg.Prefix == "" {
 		fmt.Println("(not set)")
+	} else if cfg.PrefixTemplate != "" {
+		fmt.Printf("OK (%s, rendered from %s)\n", cfg.Prefix, cfg.PrefixTemplate)
 	} else {
 		fmt.Printf("OK (%s)\n", cfg.Prefix)
 	}
 
 	defaultCredPath := config.DefaultCredentialsPath(appName)
+	if cfg.KMSKey != "" {
+		fmt.Print("[Config] KMS key configured... ")
+		if err := gcs.ValidateKMSKeyName(cfg.KMSKey); err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			fmt.Printf("  Set in: %s\n", cfg.Source("kms_key"))
+			allOK = false
+		} else {
+			fmt.Printf("OK (%s)\n", cfg.KMSKey)
+		}
+	}
+	if cfg.HasEncryptionKey() {
+		fmt.Print("[Config] Encryption key configured... ")
+		key := "encryption_key_file"
+		if cfg.EncryptionKeyFile == "" {
+			key = "encryption_key_command"
+		}
+		if _, err := cfg.EncryptionKey(context.Background()); err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			fmt.Printf("  Set in: %s\n", cfg.Source(key))
+			allOK = false
+		} else if cfg.KMSKey != "" {
+			fmt.Println("ERROR: kms_key and a customer-supplied encryption key cannot be used together")
+			allOK = false
+		} else {
+			fmt.Printf("OK (customer-supplied, from %s)\n", key)
+			fmt.Println("  Note: fetchers of signed URLs must send the x-goog-encryption-* headers")
+		}
+	}
+
 	fmt.Print("[Auth] Credentials configured... ")
-	if cfg.Credentials == "" {
+	switch {
+	case !cfg.HasCredentials():
 		fmt.Println("ERROR: credentials is not configured")
 		fmt.Println("  Set via:")
 		fmt.Println("    - --credentials flag")
 		fmt.Println("    - REPRINT_CREDENTIALS environment variable")
-		fmt.Println("    - credentials in ~/.config/reprint/config.yaml")
+		fmt.Println("    - REPRINT_CREDENTIALS_JSON environment variable (raw or base64 JSON)")
+		fmt.Println("    - credentials or credentials_command in ~/.config/reprint/config.yaml")
 		fmt.Printf("    - Place file at %s\n", defaultCredPath)
 		allOK = false
-	} else if cfg.Credentials == defaultCredPath {
+	case cfg.CredentialsJSON != "":
+		fmt.Printf("OK (inline JSON from %s)\n", cfg.Source("credentials_json"))
+	case cfg.CredentialsCommand != "":
+		// The command may carry secrets, so only say where it is set.
+		fmt.Printf("OK (credentials_command from %s)\n", cfg.Source("credentials_command"))
+	case cfg.Credentials == defaultCredPath:
 		fmt.Printf("OK (using default: %s)\n", cfg.Credentials)
-	} else {
+	default:
 		fmt.Printf("OK (%s)\n", cfg.Credentials)
 	}
 
-	if cfg.Credentials != "" {
+	if cfg.CredentialsJSON != "" || cfg.CredentialsCommand != "" {
+		if cfg.CredentialsJSON != "" {
+			fmt.Print("[Auth] Credentials readable... ")
+		} else {
+			fmt.Print("[Auth] Credentials command succeeded... ")
+		}
+		if data, err := cfg.CredentialsData(context.Background()); err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			allOK = false
+		} else if email := clientEmail(data); email != "" {
+			fmt.Printf("OK (%s)\n", email)
+		} else {
+			fmt.Println("OK")
+		}
+	} else if cfg.Credentials != "" {
 		fmt.Print("[Auth] Credentials file exists... ")
 		if _, err := os.Stat(cfg.Credentials); os.IsNotExist(err) {
 			fmt.Printf("ERROR: file not found: %s\n", cfg.Credentials)
@@ -127,9 +215,37 @@ func checkConfig() (*config.Config, bool) {
 	return cfg, allOK
 }
 
+// clientEmail returns the service account email in key JSON, if any.
+func clientEmail(data []byte) string {
+	var key struct {
+		ClientEmail string `json:"client_email"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil {
+		return ""
+	}
+	return key.ClientEmail
+}
+
+// checkDaemon reports whether upload and delete forward to a daemon. Running
+// without one is fine; only a daemon that does not respond is an error.
+func checkDaemon(ctx context.Context, cfg *config.Config) bool {
+	fmt.Print("[Daemon] Checking daemon... ")
+	_, forwarded, err := callDaemon(ctx, cfg, &daemon.Request{Op: daemon.OpPing})
+	switch {
+	case err != nil:
+		fmt.Printf("ERROR: %v\n", err)
+		return false
+	case forwarded:
+		fmt.Printf("OK (running on %s)\n", daemonSocketPath())
+	default:
+		fmt.Println("OK (not running for this configuration, commands run in-process)")
+	}
+	return true
+}
+
 func checkGCSConnection(ctx context.Context, cfg *config.Config) (*gcs.Client, bool) {
 	fmt.Print("[GCS] Connecting to GCS... ")
-	client, err := gcs.NewClient(ctx, cfg.Bucket, cfg.Prefix, cfg.Credentials)
+	client, err := newClient(ctx, cfg)
 	if err != nil {
 		fmt.Printf("ERROR: %v\n", err)
 		return nil, false
@@ -138,32 +254,96 @@ func checkGCSConnection(ctx context.Context, cfg *config.Config) (*gcs.Client, b
 	return client, true
 }
 
-func checkBucketAccess(ctx context.Context, client *gcs.Client) bool {
+func checkBucketAccess(ctx context.Context, client *gcs.Client, cfg *config.Config) bool {
 	fmt.Print("[GCS] Checking bucket access... ")
 	if err := client.CheckBucket(ctx); err != nil {
 		fmt.Printf("ERROR: %v\n", err)
+		if gcs.IsRequesterPaysError(err) {
+			printRequesterPaysHint(cfg)
+			return false
+		}
 		fmt.Println("  Required permission: storage.buckets.get")
 		fmt.Println("  Recommended role: roles/storage.bucketViewer")
 		return false
 	}
 	fmt.Println("OK")
+
+	fmt.Print("[GCS] Checking requester pays... ")
+	requesterPays, err := client.RequesterPays(ctx)
+	switch {
+	case err != nil:
+		fmt.Printf("ERROR: %v\n", err)
+		return false
+	case !requesterPays:
+		fmt.Println("OK (not requester-pays)")
+	case cfg.BillingProject == "":
+		fmt.Println("ERROR: bucket is requester-pays, but billing_project is not set")
+		printRequesterPaysHint(cfg)
+		return false
+	default:
+		fmt.Printf("OK (requester-pays, billed to %s)\n", cfg.BillingProject)
+	}
 	return true
 }
 
-func checkUploadPermission(ctx context.Context, client *gcs.Client) (string, bool) {
+// printRequesterPaysHint prints how to access a requester-pays bucket.
+func printRequesterPaysHint(cfg *config.Config) {
+	if cfg.BillingProject != "" {
+		fmt.Printf("  Billing project: %s (from %s)\n", cfg.BillingProject, cfg.Source("billing_project"))
+		fmt.Println("  Required permission on the billing project: serviceusage.services.use")
+		fmt.Println("  Recommended role: roles/serviceusage.serviceUsageConsumer")
+		return
+	}
+	fmt.Println("  The bucket is requester-pays. Set a project to bill requests to:")
+	fmt.Println("    - billing_project in the config file")
+	fmt.Println("    - REPRINT_BILLING_PROJECT environment variable")
+}
+
+func checkUploadPermission(ctx context.Context, client *gcs.Client, cfg *config.Config) (string, bool) {
 	testObjectID := ".reprint-doctor-test-" + uuid.New().String()
+	if cfg.PrefixTemplate != "" {
+		// Write where uploads go, since IAM conditions may depend on the prefix.
+		testObjectID = cfg.Prefix + testObjectID
+	}
 	testData := strings.NewReader("reprint-gcs doctor test")
 
 	fmt.Print("[GCS] Testing upload permission... ")
-	_, err := client.Upload(ctx, testObjectID, testData, "text/plain")
+	res, err := client.Upload(ctx, testObjectID, testData, "text/plain")
 	if err != nil {
 		fmt.Printf("ERROR: %v\n", err)
 		fmt.Println("  Required permission: storage.objects.create, storage.objects.get")
 		fmt.Println("  Recommended role: roles/storage.objectAdmin")
+		if cfg.KMSKey != "" {
+			printKMSHint(cfg.KMSKey)
+		}
 		return "", false
 	}
-	fmt.Println("OK")
-	return testObjectID, true
+	fmt.Printf("OK (checksums verified: %s)\n", res.Checksums)
+	return res.ID, true
+}
+
+func checkKMSKey(ctx context.Context, client *gcs.Client, cfg *config.Config, objectID string) bool {
+	fmt.Print("[GCS] Checking KMS key encryption... ")
+	obj, err := client.Stat(ctx, objectID)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		printKMSHint(cfg.KMSKey)
+		return false
+	}
+	// GCS reports the key version, e.g. <key>/cryptoKeyVersions/1.
+	if obj.KMSKeyName != cfg.KMSKey && !strings.HasPrefix(obj.KMSKeyName, cfg.KMSKey+"/") {
+		fmt.Printf("ERROR: test object is encrypted with %q, want %q\n", obj.KMSKeyName, cfg.KMSKey)
+		return false
+	}
+	fmt.Printf("OK (%s)\n", obj.KMSKeyName)
+	return true
+}
+
+// printKMSHint prints how to let GCS use a customer-managed encryption key.
+func printKMSHint(kmsKey string) {
+	fmt.Printf("  KMS key: %s\n", kmsKey)
+	fmt.Println("  The Cloud Storage service agent of the bucket's project needs roles/cloudkms.cryptoKeyEncrypterDecrypter on the key:")
+	fmt.Printf("    gcloud storage service-agent --project=PROJECT --authorize-cmek=%s\n", kmsKey)
 }
 
 func checkDeletePermission(ctx context.Context, client *gcs.Client, objectID string) bool {
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/spf13/cobra"
+)
+
+func runGC(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	filter, err := config.ParseKeyValues(filters)
+	if err != nil {
+		return err
+	}
+	if olderThan <= 0 {
+		return fmt.Errorf("--older-than must be positive")
+	}
+
+	ctx := cmd.Context()
+	client, err := newClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	objects, err := client.List(ctx, gcs.ListOptions{
+		Prefix:        listPrefix(cfg),
+		Metadata:      filter,
+		CreatedBefore: time.Now().Add(-olderThan),
+		// The prefix may be shared with objects reprint did not upload, or
+		// be the whole bucket.
+		UploadedByReprint: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	var deleted []string
+	for _, o := range objects {
+		if dryRun {
+			fmt.Printf("Would delete %s\n", o.ID)
+			continue
+		}
+		// Never delete a newer object that reused the name since listing.
+		switch err := client.DeleteGeneration(ctx, o.ID, o.Generation); {
+		case err == nil:
+			fmt.Printf("Deleted %s\n", o.ID)
+			deleted = append(deleted, o.ID)
+		case gcs.IsNotFound(err):
+			// Deleted by someone else since listing.
+			fmt.Printf("Not found %s\n", o.ID)
+			deleted = append(deleted, o.ID)
+		case errors.Is(err, gcs.ErrGenerationMismatch):
+			fmt.Printf("Skipped %s: replaced by a newer object\n", o.ID)
+		default:
+			fmt.Printf("Failed to delete %s: %v\n", o.ID, err)
+			failed++
+		}
+	}
+	recordDeletes(cfg, deleted...)
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d objects", failed, len(objects))
+	}
+	return nil
+}
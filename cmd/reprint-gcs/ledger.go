@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/minodisk/reprint/internal/ledger"
+)
+
+// openLedger returns the upload ledger, or nil if the state directory is
+// unknown.
+func openLedger() *ledger.Ledger {
+	dir := config.StateDir()
+	if dir == "" {
+		return nil
+	}
+	return ledger.New(filepath.Join(dir, "ledger.jsonl"))
+}
+
+// recordUploads adds uploaded objects to the ledger. The entries carry the
+// PID of the deck run that invoked reprint-gcs, so cleanup can tell whether
+// the run is over, and the profile and billing project to delete them with.
+func recordUploads(cfg *config.Config, results ...*gcs.UploadResult) {
+	now := time.Now()
+	pid := ledger.RunPID()
+	entries := make([]ledger.Entry, 0, len(results))
+	for _, res := range results {
+		entries = append(entries, ledger.Entry{
+			Op:             ledger.OpUpload,
+			Bucket:         cfg.Bucket,
+			Prefix:         clientPrefix(cfg),
+			ID:             res.ID,
+			Time:           now,
+			Generation:     res.Generation,
+			PID:            pid,
+			Session:        cfg.Session,
+			Profile:        cfg.Profile,
+			BillingProject: cfg.BillingProject,
+		})
+	}
+	appendLedger(entries)
+}
+
+// recordDeletes marks objects as deleted in the ledger.
+func recordDeletes(cfg *config.Config, ids ...string) {
+	now := time.Now()
+	entries := make([]ledger.Entry, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, ledger.Entry{
+			Op:     ledger.OpDelete,
+			Bucket: cfg.Bucket,
+			Prefix: clientPrefix(cfg),
+			ID:     id,
+			Time:   now,
+		})
+	}
+	appendLedger(entries)
+}
+
+// recordSchedules marks objects as scheduled for deletion at at in the
+// ledger. They stay outstanding until the delete is recorded, in case it
+// never runs.
+func recordSchedules(cfg *config.Config, at time.Time, ids ...string) {
+	now := time.Now()
+	entries := make([]ledger.Entry, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, ledger.Entry{
+			Op:       ledger.OpSchedule,
+			Bucket:   cfg.Bucket,
+			Prefix:   clientPrefix(cfg),
+			ID:       id,
+			Time:     now,
+			DeleteAt: at,
+		})
+	}
+	appendLedger(entries)
+}
+
+// appendLedger writes entries to the ledger. The objects are already
+// uploaded or deleted, so a failure is reported without failing the command.
+func appendLedger(entries []ledger.Entry) {
+	l := openLedger()
+	if l == nil || len(entries) == 0 {
+		return
+	}
+	if err := l.Append(entries...); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record in the ledger: %v\n", err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/spf13/cobra"
+)
+
+func runList(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	filter, err := config.ParseKeyValues(filters)
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	client, err := newClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	objects, err := client.List(ctx, gcs.ListOptions{
+		Prefix:   listPrefix(cfg),
+		Metadata: filter,
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSIZE\tCREATED\tUSER\tDECK")
+	for _, o := range objects {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n",
+			o.ID, o.Size, o.Created.Local().Format(time.RFC3339),
+			o.Metadata[gcs.MetadataUser], o.Metadata[gcs.MetadataDeck])
+	}
+	return w.Flush()
+}
+
+// listPrefix returns the prefix to list objects under, relative to the client
+// prefix. With a prefix template, it is the part shared by all rendered prefixes.
+func listPrefix(cfg *config.Config) string {
+	if cfg.PrefixTemplate != "" {
+		return config.StaticPrefix(cfg.PrefixTemplate)
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// setupLogging sends the log records of all commands to stderr, since stdout
+// is read by deck. It runs before each command with the log flags, and again
+// in loadConfig with the effective settings.
+func setupLogging(level, format string) error {
+	logger, err := newLogger(os.Stderr, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// newLogger returns a logger writing records of at least level to w.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	lvl, err := config.ParseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ValidateLogFormat(format); err != nil {
+		return nil, err
+	}
+
+	// The level is checked by levelHandler, so that a context can lower it.
+	opts := &slog.HandlerOptions{Level: slog.Level(math.MinInt)}
+	var h slog.Handler = slog.NewTextHandler(w, opts)
+	if strings.ToLower(format) == config.LogFormatJSON {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(&levelHandler{Handler: h, level: lvl}), nil
+}
+
+// logLevelKey is the context key of a log level set with withLogLevel.
+type logLevelKey struct{}
+
+// withLogLevel returns a context in which records of at least level are
+// logged even if the logger's level is higher, e.g., for a daemon request of
+// a more verbose caller.
+func withLogLevel(ctx context.Context, level slog.Level) context.Context {
+	return context.WithValue(ctx, logLevelKey{}, level)
+}
+
+// levelHandler passes records of at least its level, or of the level set with
+// withLogLevel if that is lower, to the wrapped handler.
+type levelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if l, ok := ctx.Value(logLevelKey{}).(slog.Level); ok && level >= l {
+		return true
+	}
+	return level >= h.level
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// setupFlagLogging sets up logging from the log flags before a command runs.
+func setupFlagLogging(cmd *cobra.Command, args []string) error {
+	level := logLevel
+	if verbose {
+		level = config.LogLevelDebug
+	}
+	return setupLogging(level, logFormat)
+}
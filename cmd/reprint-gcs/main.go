@@ -3,23 +3,62 @@ package main
 import (
 	"fmt"
 	"os"
+	"runtime/debug"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 const appName = "reprint-gcs"
 
+// version is set by GoReleaser at build time.
+var version = "dev"
+
 var (
-	bucket      string
-	prefix      string
-	credentials string
-	mime        string
-	objectID    string
+	configFile      string
+	profile         string
+	bucket          string
+	prefix          string
+	credentials     string
+	deck            string
+	mime            string
+	objectIDs       []string
+	fromFile        string
+	metadata        []string
+	printChecksum   bool
+	printGeneration bool
+	concurrency     int
+	jsonOutput      bool
+	generation      int64
+	deleteAfter     time.Duration
+	filters         []string
+	olderThan       time.Duration
+	maxAge          time.Duration
+	dryRun          bool
+	idleTimeout     time.Duration
+	verbose         bool
+	logLevel        string
+	logFormat       string
+	auditSince      time.Duration
+	auditOp         string
+	auditOutcome    string
+	auditObject     string
+	auditActor      string
+
+	configProject bool
+	configForce   bool
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "reprint-gcs",
 	Short: "External image uploader CLI for deck using Google Cloud Storage",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupFlagLogging(cmd, args); err != nil {
+			return err
+		}
+		startTelemetry(cmd)
+		return nil
+	},
 }
 
 var uploadCmd = &cobra.Command{
@@ -28,6 +67,13 @@ var uploadCmd = &cobra.Command{
 	RunE:  runUpload,
 }
 
+var uploadBatchCmd = &cobra.Command{
+	Use:   "upload-batch [FILE...]",
+	Short: "Upload many images to GCS concurrently",
+	Long:  "Upload the given image files, or the files listed one per line on stdin, and print a signed URL and id per file in order.",
+	RunE:  runUploadBatch,
+}
+
 var deleteCmd = &cobra.Command{
 	Use:   "delete",
 	Short: "Delete image from GCS",
@@ -40,26 +86,223 @@ var doctorCmd = &cobra.Command{
 	RunE:  runDoctor,
 }
 
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List uploaded images in GCS",
+	Args:  cobra.NoArgs,
+	RunE:  runList,
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete uploaded images older than a given age",
+	Args:  cobra.NoArgs,
+	RunE:  runGC,
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Serve uploads and deletes from a long-running process",
+	Long:  "Keep one GCS client alive and serve upload and delete on a Unix socket. While the daemon runs, upload and delete with the same configuration forward to it instead of creating a client of their own. The daemon exits after being idle for --idle-timeout.",
+	Args:  cobra.NoArgs,
+	RunE:  runDaemon,
+}
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Delete uploads left behind by deck runs that have exited",
+	Long:  "Delete the uploads recorded in the local ledger that were never deleted, for example because deck crashed between upload and delete. Uploads of deck runs that are still running are kept, and so are uploads of sessions until they have had no uploads for --max-age.",
+	Args:  cobra.NoArgs,
+	RunE:  runCleanup,
+}
+
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "Group the uploads of a deck run into a session",
+	Long:  "Uploads made with REPRINT_SESSION set are stored under the session id and tagged with it, so that all images of a deck run can be deleted at once with session end.",
+}
+
+var sessionStartCmd = &cobra.Command{
+	Use:     "start",
+	Short:   "Print a new session id",
+	Example: "  export REPRINT_SESSION=$(reprint-gcs session start)",
+	Args:    cobra.NoArgs,
+	RunE:    runSessionStart,
+}
+
+var sessionEndCmd = &cobra.Command{
+	Use:   "end [SESSION]",
+	Short: "Delete all uploads of a session",
+	Long:  "Delete all uploads of the given session, or of REPRINT_SESSION if none is given.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runSessionEnd,
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local upload cache",
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Forget all cached uploads",
+	Args:  cobra.NoArgs,
+	RunE:  runCacheClean,
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the audit log of uploads and deletes",
+	Long:  "Print the records of the audit log (audit_log), including its rotated files, oldest first.",
+	Args:  cobra.NoArgs,
+	RunE:  runAudit,
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and edit configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show effective configuration and where each value came from",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigShow,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Print a value from the config file",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE",
+	Short: "Set a value in the config file",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runConfigSet,
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset KEY",
+	Short: "Remove a value from the config file",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigUnset,
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a config file interactively",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigInit,
+}
+
 func init() {
+	if version == "dev" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+	}
+	rootCmd.Version = version
+
 	// Root flags
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file path (default: ~/.config/reprint/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Config profile name")
 	rootCmd.PersistentFlags().StringVar(&bucket, "bucket", "", "GCS bucket name")
 	rootCmd.PersistentFlags().StringVar(&prefix, "prefix", "", "Object prefix")
 	rootCmd.PersistentFlags().StringVar(&credentials, "credentials", "", "Service account key file path")
+	rootCmd.PersistentFlags().StringVar(&deck, "deck", "", "Deck name or file for prefix templates")
+
+	// Logging flags
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Log debug details to stderr (same as --log-level debug)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "Minimum level of logs on stderr: debug, info, warn, or error (default: warn)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", "Format of logs on stderr: text or json (default: text)")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "log-level")
 
 	// Upload flags
 	uploadCmd.Flags().StringVar(&mime, "mime", "", "Image MIME type")
+	uploadCmd.Flags().BoolVar(&printChecksum, "print-checksum", false, "Print the verified CRC32C and MD5 checksums to stderr")
+	uploadCmd.Flags().BoolVar(&printGeneration, "print-generation", false, "Print the object generation to stderr")
+
+	// Upload batch flags
+	uploadBatchCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print one JSON object per file instead of URL and id lines")
+
+	// Object flags
+	for _, c := range []*cobra.Command{uploadCmd, uploadBatchCmd} {
+		c.Flags().StringArrayVar(&metadata, "metadata", nil, "Custom object metadata as key=value (repeatable)")
+	}
 
 	// Delete flags
-	deleteCmd.Flags().StringVar(&objectID, "object-id", "", "Object ID to delete")
+	deleteCmd.Flags().StringArrayVar(&objectIDs, "object-id", nil, "Object ID to delete (repeatable)")
+	deleteCmd.Flags().StringVar(&fromFile, "from-file", "", "Read object IDs to delete, one per line, from a file (\"-\" for stdin)")
+	deleteCmd.Flags().Int64Var(&generation, "generation", 0, "Only delete the object if its generation matches")
+	deleteCmd.Flags().DurationVar(&deleteAfter, "after", 0, "Schedule the deletion for this long from now instead of deleting right away (to the day without a daemon)")
+
+	// Batch flags
+	for _, c := range []*cobra.Command{uploadBatchCmd, deleteCmd, sessionEndCmd} {
+		c.Flags().IntVar(&concurrency, "concurrency", 8, "Maximum number of concurrent requests")
+	}
+
+	// List and gc flags
+	for _, c := range []*cobra.Command{listCmd, gcCmd} {
+		c.Flags().StringArrayVar(&filters, "filter", nil, "Only include objects with metadata key=value (repeatable)")
+	}
+	gcCmd.Flags().DurationVar(&olderThan, "older-than", 24*time.Hour, "Only delete objects created longer ago than this")
+	cleanupCmd.Flags().DurationVar(&maxAge, "max-age", 7*24*time.Hour, "Also delete uploads of sessions without uploads for this long (0 keeps them until session end)")
+
+	// Bulk delete flags
+	for _, c := range []*cobra.Command{gcCmd, cleanupCmd, sessionEndCmd} {
+		c.Flags().BoolVar(&dryRun, "dry-run", false, "Print objects that would be deleted without deleting them")
+	}
+
+	// Daemon flags
+	daemonCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 15*time.Minute, "Exit after no requests for this long")
+
+	// Audit flags
+	auditCmd.Flags().DurationVar(&auditSince, "since", 0, "Only include records from this long ago until now")
+	auditCmd.Flags().StringVar(&auditOp, "op", "", "Only include records of an operation: upload, delete, or schedule_delete")
+	auditCmd.Flags().StringVar(&auditOutcome, "outcome", "", "Only include records with an outcome: ok, cached, not_found, or failed")
+	auditCmd.Flags().StringVar(&auditObject, "object", "", "Only include records whose object name contains this")
+	auditCmd.Flags().StringVar(&auditActor, "actor", "", "Only include records of a service account email")
+	auditCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the records as JSON lines")
+
+	// Config flags
+	for _, c := range []*cobra.Command{configGetCmd, configSetCmd, configUnsetCmd, configInitCmd} {
+		c.Flags().BoolVar(&configProject, "project", false, "Use the project config file (.reprint.yaml) instead of the user config file")
+	}
+	configInitCmd.Flags().BoolVar(&configForce, "force", false, "Overwrite an existing config file")
+
+	sessionCmd.AddCommand(sessionStartCmd)
+	sessionCmd.AddCommand(sessionEndCmd)
+
+	cacheCmd.AddCommand(cacheCleanCmd)
+
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configInitCmd)
 
 	// Add subcommands
 	rootCmd.AddCommand(uploadCmd)
+	rootCmd.AddCommand(uploadBatchCmd)
 	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(cleanupCmd)
+	rootCmd.AddCommand(sessionCmd)
 	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(configCmd)
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	finishTelemetry(err)
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
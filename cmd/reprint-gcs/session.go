@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/spf13/cobra"
+)
+
+func runSessionStart(cmd *cobra.Command, args []string) error {
+	// Printed alone, so that it can be captured by the shell.
+	fmt.Println(config.NewSessionID(time.Now()))
+	return nil
+}
+
+func runSessionEnd(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+
+	session := cfg.Session
+	if len(args) > 0 {
+		session = args[0]
+	}
+	if session == "" {
+		return fmt.Errorf("session is required (argument or REPRINT_SESSION)")
+	}
+	if err := config.ValidateSession(session); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	client, err := newClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Uploads of a session are under its sub-prefix, which a prefix template
+	// puts below the rendered prefix, so the metadata decides.
+	opts := gcs.ListOptions{
+		Prefix:   session + "/",
+		Metadata: map[string]string{gcs.MetadataSession: session},
+	}
+	if cfg.PrefixTemplate != "" {
+		opts.Prefix = listPrefix(cfg)
+	}
+	objects, err := client.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		fmt.Fprintf(os.Stderr, "No objects in session %s\n", session)
+		return nil
+	}
+
+	failed := 0
+	var deleted []string
+	err = forEachOrdered(len(objects), concurrency, func(i int) error {
+		if dryRun {
+			return nil
+		}
+		// Never delete a newer object that reused the name since listing.
+		return client.DeleteGeneration(ctx, objects[i].ID, objects[i].Generation)
+	}, func(i int, err error) error {
+		id := objects[i].ID
+		switch {
+		case dryRun:
+			fmt.Printf("Would delete %s\n", id)
+		case err == nil:
+			fmt.Printf("Deleted %s\n", id)
+			deleted = append(deleted, id)
+		case gcs.IsNotFound(err):
+			fmt.Printf("Not found %s\n", id)
+			deleted = append(deleted, id)
+		case errors.Is(err, gcs.ErrGenerationMismatch):
+			fmt.Printf("Skipped %s: replaced by a newer object\n", id)
+		default:
+			fmt.Printf("Failed to delete %s: %v\n", id, err)
+			failed++
+		}
+		return nil
+	})
+	recordDeletes(cfg, deleted...)
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d objects", failed, len(objects))
+	}
+	return nil
+}
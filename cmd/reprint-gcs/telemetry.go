@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/minodisk/reprint/internal/telemetry"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// telemetryShutdownTimeout bounds the time spent flushing telemetry when a
+// command exits, so that an unreachable collector does not stall deck.
+const telemetryShutdownTimeout = 5 * time.Second
+
+// tracer creates the spans of the commands. It follows the provider installed
+// by startTelemetry.
+var tracer = otel.Tracer("github.com/minodisk/reprint/cmd/reprint-gcs")
+
+var (
+	// commandSpan is the span of the running command.
+	commandSpan trace.Span = noop.Span{}
+	// shutdownTelemetry flushes and stops the exporters.
+	shutdownTelemetry = func(context.Context) error { return nil }
+)
+
+// startTelemetry sets up tracing and metrics before a command runs, and
+// starts the span of the command under the trace of the parent process, if
+// any. Telemetry is set up before the config is loaded, so it is configured by
+// the OTEL_* environment variables and REPRINT_TELEMETRY_FILE only. A failure
+// is logged rather than failing the command.
+func startTelemetry(cmd *cobra.Command) {
+	shutdown, err := telemetry.Setup(cmd.Context(), telemetry.Options{
+		ServiceName:    appName,
+		ServiceVersion: version,
+		File:           os.Getenv("REPRINT_TELEMETRY_FILE"),
+	})
+	if err != nil {
+		slog.Warn("telemetry disabled", "error", err)
+		return
+	}
+	shutdownTelemetry = shutdown
+
+	ctx, span := tracer.Start(telemetry.ContextFromEnv(cmd.Context()), cmd.CommandPath())
+	commandSpan = span
+	cmd.SetContext(ctx)
+}
+
+// finishTelemetry ends the span of the command with its error, and flushes
+// the spans and metrics.
+func finishTelemetry(err error) {
+	endSpan(commandSpan, err)
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryShutdownTimeout)
+	defer cancel()
+	if err := shutdownTelemetry(ctx); err != nil {
+		slog.Warn("failed to export telemetry", "error", err)
+	}
+}
+
+// endSpan ends span, marking it failed if err is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,17 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
-	"github.com/google/uuid"
+	"github.com/minodisk/reprint/internal/config"
+	"github.com/minodisk/reprint/internal/daemon"
 	"github.com/minodisk/reprint/internal/gcs"
 	"github.com/spf13/cobra"
 )
 
 func runUpload(cmd *cobra.Command, args []string) error {
-	cfg, err := loadConfig()
+	cfg, err := loadConfig(cmd.Context())
 	if err != nil {
 		return err
 	}
@@ -24,25 +28,66 @@ func runUpload(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("MIME type is required (--mime or DECK_UPLOAD_MIME)")
 	}
 
-	ctx := context.Background()
-	client, err := gcs.NewClient(ctx, cfg.Bucket, cfg.Prefix, cfg.Credentials)
+	// Generate UUID filename
+	filename, err := newObjectID(cfg)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
 
-	// Generate UUID filename
-	filename := uuid.New().String()
-
-	// Read from stdin and upload
-	url, err := client.Upload(ctx, filename, os.Stdin, mime)
+	res, err := uploadImage(cmd.Context(), cfg, filename, os.Stdin)
 	if err != nil {
 		return err
 	}
 
-	// Output URL and filename
-	fmt.Println(url)
-	fmt.Println(filename)
+	recordUploads(cfg, res)
+
+	// Output URL and object id. A resumed upload keeps its original id.
+	fmt.Println(res.URL)
+	fmt.Println(res.ID)
+
+	// stdout is read by deck, so details go to stderr
+	details := []string{res.ID}
+	if printGeneration {
+		details = append(details, fmt.Sprintf("generation=%d", res.Generation))
+	}
+	if printChecksum {
+		details = append(details, res.Checksums.String())
+	}
+	if len(details) > 1 {
+		fmt.Fprintln(os.Stderr, strings.Join(details, " "))
+	}
 
 	return nil
 }
+
+// uploadImage uploads the image read from r, through the daemon if one
+// serves cfg. The daemon needs the whole image, so it is only read into memory
+// for the daemon, and streamed to GCS otherwise.
+func uploadImage(ctx context.Context, cfg *config.Config, filename string, r io.Reader) (*gcs.UploadResult, error) {
+	_, forwarded, err := callDaemon(ctx, cfg, &daemon.Request{Op: daemon.OpPing})
+	if err != nil {
+		return nil, err
+	}
+	if forwarded {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image from stdin: %w", err)
+		}
+		resp, forwarded, err := callDaemon(ctx, cfg, &daemon.Request{Op: daemon.OpUpload, ID: filename, MIME: mime, Data: data})
+		if err != nil {
+			return nil, err
+		}
+		if forwarded {
+			return resp.Upload, nil
+		}
+		// The daemon stopped since the ping.
+		r = bytes.NewReader(data)
+	}
+
+	client, err := newClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return client.Upload(ctx, filename, r, mime)
+}
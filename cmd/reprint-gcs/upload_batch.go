@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	mimetype "mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/minodisk/reprint/internal/gcs"
+	"github.com/spf13/cobra"
+)
+
+// batchUploadResult is the outcome of uploading one file of a batch, printed
+// as a JSON line with --json.
+type batchUploadResult struct {
+	File       string `json:"file"`
+	MIME       string `json:"mime,omitempty"`
+	URL        string `json:"url,omitempty"`
+	ID         string `json:"id,omitempty"`
+	Generation int64  `json:"generation,omitempty"`
+	Cached     bool   `json:"cached,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func runUploadBatch(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	// Fail before reading the manifest rather than once per file.
+	if _, err := cfg.RenderedPrefix(); err != nil {
+		return err
+	}
+
+	files := args
+	if len(files) == 0 {
+		if files, err = readLines(os.Stdin); err != nil {
+			return fmt.Errorf("failed to read manifest from stdin: %w", err)
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files to upload (pass files as arguments or one path per line on stdin)")
+	}
+
+	ctx := cmd.Context()
+	client, err := newClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Upload concurrently, but print results in the order of the files.
+	enc := json.NewEncoder(os.Stdout)
+	failed := 0
+	var uploaded []*gcs.UploadResult
+	err = forEachOrdered(len(files), concurrency, func(i int) batchUploadResult {
+		id, err := newObjectID(cfg)
+		if err != nil {
+			return batchUploadResult{File: files[i], Error: err.Error()}
+		}
+		return uploadFile(ctx, client, id, files[i])
+	}, func(_ int, r batchUploadResult) error {
+		if r.Error != "" {
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed to upload %s: %s\n", r.File, r.Error)
+		} else {
+			uploaded = append(uploaded, &gcs.UploadResult{ID: r.ID, Generation: r.Generation})
+		}
+		switch {
+		case jsonOutput:
+			return enc.Encode(r)
+		case r.Error == "":
+			fmt.Println(r.URL)
+			fmt.Println(r.ID)
+		}
+		return nil
+	})
+	recordUploads(cfg, uploaded...)
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to upload %d of %d files", failed, len(files))
+	}
+	return nil
+}
+
+// uploadFile uploads a single file of a batch with its detected MIME type.
+func uploadFile(ctx context.Context, client *gcs.Client, id, file string) batchUploadResult {
+	r := batchUploadResult{File: file}
+
+	f, err := os.Open(file)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	defer f.Close()
+
+	if r.MIME, err = detectMIME(f); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+
+	res, err := client.Upload(ctx, id, f, r.MIME)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.URL, r.ID, r.Generation, r.Cached = res.URL, res.ID, res.Generation, res.Cached
+	return r
+}
+
+// detectMIME returns the MIME type of a file from its extension or, if the
+// extension is unknown, its content. It leaves f positioned at the start.
+func detectMIME(f *os.File) (string, error) {
+	if t := mimetype.TypeByExtension(strings.ToLower(filepath.Ext(f.Name()))); t != "" {
+		return t, nil
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/minodisk/reprint/internal/gcs"
+)
+
+func TestUploadBatch_Metadata(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("REPRINT_BUCKET", "test-bucket")
+	t.Setenv("REPRINT_CREDENTIALS", filepath.Join(dir, "credentials.json"))
+	t.Setenv("REPRINT_METADATA", "team=docs,owner=alice")
+	t.Cleanup(func() { metadata = nil })
+
+	args := []string{"--metadata", "team=slides", "slide1.png"}
+	if err := uploadBatchCmd.ParseFlags(args); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	cfg, err := loadConfig(context.Background())
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	md := objectMetadata(cfg)
+	// Flags take precedence over the setting.
+	if md["team"] != "slides" || md["owner"] != "alice" {
+		t.Errorf("metadata = %v, want team=slides from --metadata and owner=alice from REPRINT_METADATA", md)
+	}
+	if md[gcs.MetadataVersion] != version {
+		t.Errorf("metadata = %v, want the standard reprint-* metadata", md)
+	}
+}
+
+func TestDetectMIME(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		want    string
+	}{
+		{name: "extension", file: "slide.png", content: png, want: "image/png"},
+		{name: "extension wins over content", file: "slide.jpg", content: png, want: "image/jpeg"},
+		{name: "upper case extension", file: "slide.PNG", content: png, want: "image/png"},
+		{name: "sniffed without extension", file: "slide", content: png, want: "image/png"},
+		{name: "sniffed with unknown extension", file: "slide.screenshot", content: png, want: "image/png"},
+		{name: "sniffed text", file: "notes", content: []byte("hello"), want: "text/plain; charset=utf-8"},
+		{name: "empty", file: "empty", content: nil, want: "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, tt.content, 0600); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := detectMIME(f)
+			if err != nil {
+				t.Fatalf("detectMIME() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("detectMIME() = %q, want %q", got, tt.want)
+			}
+			// The upload reads the file from the start.
+			if rest, err := io.ReadAll(f); err != nil || !bytes.Equal(rest, tt.content) {
+				t.Errorf("file content after detectMIME() = %q, %v, want all of it", rest, err)
+			}
+		})
+	}
+}
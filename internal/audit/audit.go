@@ -0,0 +1,182 @@
+// Package audit writes a local trail of the objects uploaded and deleted, as
+// JSON records appended to a file that is rotated by size.
+package audit
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minodisk/reprint/internal/jsonl"
+)
+
+// Operations of a Record.
+const (
+	OpUpload         = "upload"          // an object was uploaded or reused
+	OpDelete         = "delete"          // an object was deleted
+	OpScheduleDelete = "schedule_delete" // an object was scheduled for deletion
+)
+
+// Outcomes of a Record.
+const (
+	OutcomeOK       = "ok"        // the operation succeeded
+	OutcomeCached   = "cached"    // an earlier upload of the same content was reused
+	OutcomeNotFound = "not_found" // the object to delete was already gone
+	OutcomeFailed   = "failed"    // the operation failed, see Error
+)
+
+const (
+	// DefaultMaxSize is the size at which the log is rotated by default.
+	DefaultMaxSize = 10 << 20
+	// MaxBackups is the number of rotated files kept, as path.1 (newest)
+	// to path.5 (oldest).
+	MaxBackups = 5
+)
+
+// Record is a line of the audit log.
+type Record struct {
+	Time time.Time `json:"time"`
+	Op   string    `json:"op"`
+	// Actor is the service account that made the request, if known.
+	Actor      string `json:"actor,omitempty"`
+	Bucket     string `json:"bucket"`
+	Object     string `json:"object"`
+	Generation int64  `json:"generation,omitempty"`
+	// Size, SHA256 and MIME describe the content of an upload.
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	MIME   string `json:"mime,omitempty"`
+	// URLExpires is when the signed URL of an upload expires. The URL itself
+	// grants access, so it is not recorded.
+	URLExpires time.Time `json:"url_expires,omitzero"`
+	// DeleteAt is when a scheduled deletion is due.
+	DeleteAt time.Time `json:"delete_at,omitzero"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Filter selects records. Zero fields match any record.
+type Filter struct {
+	Since   time.Time
+	Until   time.Time
+	Op      string
+	Outcome string
+	Actor   string
+	// Object matches records whose object name contains it.
+	Object string
+}
+
+// Match reports whether r is selected by the filter.
+func (f Filter) Match(r Record) bool {
+	return (f.Since.IsZero() || !r.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || r.Time.Before(f.Until)) &&
+		(f.Op == "" || r.Op == f.Op) &&
+		(f.Outcome == "" || r.Outcome == f.Outcome) &&
+		(f.Actor == "" || r.Actor == f.Actor) &&
+		(f.Object == "" || strings.Contains(r.Object, f.Object))
+}
+
+// Log is an append-only file of records. Its methods may be called
+// concurrently from several processes; they serialize on a lock file next to
+// the log.
+type Log struct {
+	path    string
+	maxSize int64
+}
+
+// New returns the audit log stored at path, rotated once it would grow past
+// maxSize bytes (DefaultMaxSize if not positive). The file is created on
+// first write.
+func New(path string, maxSize int64) *Log {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &Log{path: path, maxSize: maxSize}
+}
+
+// Path returns the path of the audit log file.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append adds records to the log, rotating it first if they would make it
+// larger than its maximum size.
+func (l *Log) Append(records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	buf, err := jsonl.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := l.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if fi, err := os.Stat(l.path); err == nil && fi.Size() > 0 && fi.Size()+int64(len(buf)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	if err := jsonl.Append(l.path, buf); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Read returns the records selected by filter from the log and its rotated
+// files, oldest first.
+func (l *Log) Read(filter Filter) ([]Record, error) {
+	unlock, err := l.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var records []Record
+	for i := MaxBackups; i >= 0; i-- {
+		err := jsonl.Read(l.backup(i), func(r Record) {
+			if filter.Match(r) {
+				records = append(records, r)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+	return records, nil
+}
+
+// rotate shifts the log to its first backup and each backup to the next,
+// dropping the oldest. The caller holds the exclusive lock.
+func (l *Log) rotate() error {
+	for i := MaxBackups; i > 0; i-- {
+		if err := os.Rename(l.backup(i-1), l.backup(i)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	return nil
+}
+
+// backup returns the path of the i-th rotated file, or of the log for 0.
+func (l *Log) backup(i int) string {
+	if i == 0 {
+		return l.path
+	}
+	return l.path + "." + strconv.Itoa(i)
+}
+
+// lock takes the lock of the log, exclusively for writes, and returns a
+// function that releases it.
+func (l *Log) lock(exclusive bool) (func(), error) {
+	unlock, err := jsonl.Lock(l.path, exclusive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	return unlock, nil
+}
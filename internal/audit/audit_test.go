@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func record(object, op, outcome string, at time.Time) Record {
+	return Record{Time: at, Op: op, Bucket: "bucket", Object: object, Outcome: outcome}
+}
+
+func objects(records []Record) []string {
+	var objects []string
+	for _, r := range records {
+		objects = append(objects, r.Object)
+	}
+	return objects
+}
+
+func TestLog_Read(t *testing.T) {
+	l := New(filepath.Join(t.TempDir(), "audit", "audit.jsonl"), 0)
+
+	if got, err := l.Read(Filter{}); err != nil || len(got) != 0 {
+		t.Fatalf("Read() of a missing log = %v, %v, want none", got, err)
+	}
+
+	now := time.Now()
+	if err := l.Append(
+		record("deck/a", OpUpload, OutcomeOK, now.Add(-2*time.Hour)),
+		record("deck/b", OpUpload, OutcomeFailed, now.Add(-time.Hour)),
+		record("other/c", OpUpload, OutcomeCached, now),
+		record("deck/a", OpDelete, OutcomeOK, now),
+	); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{name: "all", want: "[deck/a deck/b other/c deck/a]"},
+		{name: "since", filter: Filter{Since: now.Add(-90 * time.Minute)}, want: "[deck/b other/c deck/a]"},
+		{name: "until", filter: Filter{Until: now.Add(-90 * time.Minute)}, want: "[deck/a]"},
+		{name: "op", filter: Filter{Op: OpDelete}, want: "[deck/a]"},
+		{name: "outcome", filter: Filter{Outcome: OutcomeFailed}, want: "[deck/b]"},
+		{name: "object", filter: Filter{Object: "deck/", Op: OpUpload}, want: "[deck/a deck/b]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Read(tt.filter)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if fmt.Sprint(objects(got)) != tt.want {
+				t.Errorf("Read() = %v, want %s", objects(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestLog_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	// Each record is about 100 bytes, so every file holds two of them.
+	l := New(path, 250)
+
+	now := time.Now()
+	for i := range 20 {
+		if err := l.Append(record(fmt.Sprintf("deck/%02d", i), OpUpload, OutcomeOK, now)); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	for i := 0; i <= MaxBackups; i++ {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("rotated file %d: %v", i, err)
+		}
+		if fi.Size() > 250 {
+			t.Errorf("rotated file %d is %d bytes, want at most 250", i, fi.Size())
+		}
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s.%d", path, MaxBackups+1)); !os.IsNotExist(err) {
+		t.Errorf("more than %d rotated files are kept", MaxBackups)
+	}
+
+	got, err := l.Read(Filter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := 2 * (MaxBackups + 1)
+	if len(got) != want || got[0].Object != fmt.Sprintf("deck/%02d", 20-want) || got[len(got)-1].Object != "deck/19" {
+		t.Errorf("Read() = %v, want the last %d records in order", objects(got), want)
+	}
+}
+
+func TestLog_SkipsTruncatedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := New(path, 0)
+	if err := l.Append(record("deck/a", OpUpload, OutcomeOK, time.Now())); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	// A crash in the middle of a write leaves a partial line.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{\"time\":\"2024-03-05T09:30:00Z\",\"op\":\"up\n")
+	f.Close()
+
+	got, err := l.Read(Filter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if fmt.Sprint(objects(got)) != "[deck/a]" {
+		t.Errorf("Read() = %v, want [deck/a]", objects(got))
+	}
+}
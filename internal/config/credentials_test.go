@@ -0,0 +1,117 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+const testKeyJSON = `{"type":"service_account","client_email":"sa@example.iam.gserviceaccount.com"}`
+
+func TestConfig_CredentialsData(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "none",
+			cfg:  Config{Credentials: "/path/to/creds.json"},
+			want: "",
+		},
+		{
+			name: "raw JSON",
+			cfg:  Config{CredentialsJSON: "  " + testKeyJSON + "\n"},
+			want: testKeyJSON,
+		},
+		{
+			name: "base64 JSON",
+			cfg:  Config{CredentialsJSON: base64.StdEncoding.EncodeToString([]byte(testKeyJSON))},
+			want: testKeyJSON,
+		},
+		{
+			name: "unpadded base64 JSON",
+			cfg:  Config{CredentialsJSON: base64.RawStdEncoding.EncodeToString([]byte(testKeyJSON))},
+			want: testKeyJSON,
+		},
+		{
+			name:    "invalid",
+			cfg:     Config{CredentialsJSON: "not a key"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			cfg:     Config{CredentialsJSON: "{not json"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.CredentialsData(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CredentialsData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("CredentialsData() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_CredentialsData_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell")
+	}
+
+	cfg := Config{CredentialsCommand: "printf '%s' '" + testKeyJSON + "'"}
+	got, err := cfg.CredentialsData(context.Background())
+	if err != nil {
+		t.Fatalf("CredentialsData() error = %v", err)
+	}
+	if string(got) != testKeyJSON {
+		t.Errorf("CredentialsData() = %q, want %q", got, testKeyJSON)
+	}
+
+	cfg = Config{CredentialsCommand: "exit 1"}
+	if _, err := cfg.CredentialsData(context.Background()); err == nil {
+		t.Error("CredentialsData() should fail when the command fails")
+	}
+}
+
+func TestLoad_CredentialsJSONFromEnv(t *testing.T) {
+	os.Unsetenv("REPRINT_CREDENTIALS")
+	t.Chdir(t.TempDir())
+	t.Setenv("REPRINT_CREDENTIALS_JSON", testKeyJSON)
+
+	// A default credentials file must not be picked up over inline credentials
+	home := t.TempDir()
+	appName := "test-app"
+	credDir := filepath.Join(home, ".config", appName)
+	if err := os.MkdirAll(credDir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(credDir, DefaultCredentialsFilename), []byte("{}"), 0644); err != nil {
+		t.Fatalf("failed to create credentials file: %v", err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	cfg, err := Load(WithAppName(appName))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.CredentialsJSON != testKeyJSON {
+		t.Errorf("CredentialsJSON = %q, want %q", cfg.CredentialsJSON, testKeyJSON)
+	}
+	if cfg.Credentials != "" {
+		t.Errorf("Credentials = %q, want empty", cfg.Credentials)
+	}
+	if got, _ := cfg.Value("credentials_json"); got != "(hidden)" {
+		t.Errorf("Value(credentials_json) = %q, want %q", got, "(hidden)")
+	}
+}
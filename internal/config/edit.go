@@ -0,0 +1,291 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a YAML config file opened for editing.
+// Edits keep the comments and ordering of the original file.
+type File struct {
+	path string
+	root yaml.Node
+}
+
+// OpenFile opens the config file at path for editing.
+// A missing file is treated as empty and created by Save.
+func OpenFile(path string) (*File, error) {
+	f := &File{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &f.root); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+	if f.root.Kind == 0 {
+		f.root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if len(f.root.Content) == 0 || f.root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s: top level must be a mapping", path)
+	}
+
+	return f, nil
+}
+
+// Path returns the path of the config file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Get returns the value of key in profile, or at the top level when profile
+// is empty. Nested keys are separated by dots (e.g., "metadata.team").
+func (f *File) Get(profile, key string) (string, bool) {
+	node := f.root.Content[0]
+	path := f.keyPath(profile, key)
+	for i, name := range path {
+		node = lookup(node, name, foldKey(key, path, i))
+		if node == nil {
+			return "", false
+		}
+	}
+	if node.Kind != yaml.ScalarNode {
+		out, err := yaml.Marshal(node)
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimRight(string(out), "\n"), true
+	}
+	return node.Value, true
+}
+
+// Set sets key in profile, or at the top level when profile is empty.
+// The value is checked and written with the type of the setting, e.g. as a
+// YAML boolean. Intermediate mappings are created as needed.
+func (f *File) Set(profile, key, value string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	value, tag, err := settingValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	path := f.keyPath(profile, key)
+	node := f.root.Content[0]
+	for i, name := range path {
+		child := lookup(node, name, foldKey(key, path, i))
+		last := i == len(path)-1
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if last {
+				child = &yaml.Node{Kind: yaml.ScalarNode}
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, child)
+		}
+		if last {
+			if child.Kind != yaml.ScalarNode {
+				return fmt.Errorf("%s is not a single value", strings.Join(path, "."))
+			}
+			child.SetString(value)
+			if tag != "" {
+				child.Tag, child.Style = tag, 0
+			}
+			return nil
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", strings.Join(path[:i+1], "."))
+		}
+		node = child
+	}
+	return nil
+}
+
+// Unset removes key from profile, or from the top level when profile is
+// empty. It returns false if the key was not set.
+func (f *File) Unset(profile, key string) bool {
+	path := f.keyPath(profile, key)
+	node := f.root.Content[0]
+	for i, name := range path[:len(path)-1] {
+		node = lookup(node, name, foldKey(key, path, i))
+		if node == nil || node.Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	last, fold := path[len(path)-1], foldKey(key, path, len(path)-1)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if keyEqual(node.Content[i].Value, last, fold) {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the config file atomically, creating its directory if needed.
+func (f *File) Save() error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&f.root); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	mode := fs.FileMode(0o600)
+	if info, err := os.Stat(f.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	// Write to a temporary file in the same directory and rename it, so a
+	// failed write never leaves a truncated config file behind.
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// keyPath returns the node path of key within profile. Profile names are
+// lowercased like the loader does.
+func (f *File) keyPath(profile, key string) []string {
+	path := strings.Split(key, ".")
+	if profile != "" {
+		path = append([]string{"profiles", strings.ToLower(profile)}, path...)
+	}
+	return path
+}
+
+// foldKey reports whether the i-th element of the node path of key matches
+// case-insensitively, like the loader reads keys and profile names. Keys
+// below map settings are case-sensitive.
+func foldKey(key string, path []string, i int) bool {
+	return !strings.Contains(key, ".") || i < len(path)-1
+}
+
+// settingValue checks value against the type of the setting key and returns
+// it in canonical form, with the YAML tag to write it with if it is not a
+// string.
+func settingValue(key, value string) (string, string, error) {
+	name, _, nested := strings.Cut(key, ".")
+	if nested || key == "default_profile" {
+		return value, "", nil
+	}
+	field, _ := settingField(name)
+	switch {
+	case field.Type == reflect.TypeOf(time.Duration(0)):
+		if _, err := time.ParseDuration(value); err != nil {
+			return "", "", fmt.Errorf("invalid %s %q: must be a duration such as 30s or 5m", key, value)
+		}
+	case field.Type.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid %s %q: must be true or false", key, value)
+		}
+		return strconv.FormatBool(b), "!!bool", nil
+	case field.Type.Kind() == reflect.Int || field.Type.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid %s %q: must be an integer", key, value)
+		}
+		return strconv.FormatInt(n, 10), "!!int", nil
+	}
+	if validate, ok := settingValidators[key]; ok {
+		if err := validate(value); err != nil {
+			return "", "", err
+		}
+	}
+	return value, "", nil
+}
+
+// settingValidators check the values of string settings with a format.
+var settingValidators = map[string]func(string) error{
+	"log_level":          func(s string) error { _, err := ParseLogLevel(s); return err },
+	"log_format":         ValidateLogFormat,
+	"session":            ValidateSession,
+	"chunk_size":         validateSize,
+	"audit_log_max_size": validateSize,
+}
+
+func validateSize(s string) error {
+	_, err := ParseSize(s)
+	return err
+}
+
+// checkKey returns an error if key is not a setting that can be written to a
+// config file. Nested keys are only allowed below map settings.
+func checkKey(key string) error {
+	name, sub, nested := strings.Cut(key, ".")
+	if key == "default_profile" {
+		return nil
+	}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("mapstructure") != name {
+			continue
+		}
+		if nested && (field.Type.Kind() != reflect.Map || sub == "" || strings.Contains(sub, ".")) {
+			return fmt.Errorf("config key %q does not accept nested keys", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown config key %q (known keys: %s)", key, strings.Join(Keys(), ", "))
+}
+
+// lookup returns the value node for key in a mapping node, matching
+// case-insensitively if fold is set.
+func lookup(node *yaml.Node, key string, fold bool) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if keyEqual(node.Content[i].Value, key, fold) {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func keyEqual(a, b string, fold bool) bool {
+	if fold {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
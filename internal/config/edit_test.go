@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFile_SetGetUnset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	original := "# shared settings\nbucket: old-bucket # keep me\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if err := f.Set("", "bucket", "new-bucket"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := f.Set("customer", "prefix", "customer/"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config file: %v", err)
+	}
+	for _, want := range []string{"# shared settings", "bucket: new-bucket # keep me", "profiles:\n  customer:\n    prefix: customer/"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config file = %q, want to contain %q", data, want)
+		}
+	}
+
+	f, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if got, ok := f.Get("customer", "prefix"); !ok || got != "customer/" {
+		t.Errorf("Get(customer, prefix) = %q, %v, want %q, true", got, ok, "customer/")
+	}
+	if !f.Unset("customer", "prefix") {
+		t.Error("Unset(customer, prefix) = false, want true")
+	}
+	if f.Unset("customer", "prefix") {
+		t.Error("Unset(customer, prefix) = true for unset key, want false")
+	}
+	if _, ok := f.Get("customer", "prefix"); ok {
+		t.Error("Get(customer, prefix) should fail after Unset")
+	}
+}
+
+func TestFile_SetUnknownKey(t *testing.T) {
+	f, err := OpenFile(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if err := f.Set("", "buket", "x"); err == nil {
+		t.Error("Set() should fail for unknown key")
+	}
+	if err := f.Set("", "bucket.name", "x"); err == nil {
+		t.Error("Set() should fail for nested key of a single value setting")
+	}
+}
+
+func TestFile_SaveCreatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reprint", "config.yaml")
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if err := f.Set("", "bucket", "my-bucket"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("config file not created: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config file: %v", err)
+	}
+	if string(data) != "bucket: my-bucket\n" {
+		t.Errorf("config file = %q, want %q", data, "bucket: my-bucket\n")
+	}
+}
+
+func TestFile_SetTyped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	for key, value := range map[string]string{
+		"resumable":            "TRUE",
+		"chunk_retry_deadline": "30s",
+		"chunk_size":           "0",
+		"log_level":            "debug",
+		"metadata.team":        "true",
+	} {
+		if err := f.Set("", key, value); err != nil {
+			t.Errorf("Set(%s, %s) error = %v", key, value, err)
+		}
+	}
+	for key, value := range map[string]string{
+		"resumable":            "yes please",
+		"chunk_retry_deadline": "soon",
+		"chunk_size":           "big",
+		"log_level":            "loud",
+		"session":              "../escape",
+	} {
+		if err := f.Set("", key, value); err == nil {
+			t.Errorf("Set(%s, %s) should fail", key, value)
+		}
+	}
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config file: %v", err)
+	}
+	settings, _, err := parseConfigFile(path, data)
+	if err != nil {
+		t.Fatalf("parseConfigFile() error = %v", err)
+	}
+	if settings["resumable"] != true {
+		t.Errorf("resumable = %#v, want true", settings["resumable"])
+	}
+	if settings["chunk_size"] != "0" || settings["chunk_retry_deadline"] != "30s" {
+		t.Errorf("chunk_size, chunk_retry_deadline = %#v, %#v, want strings", settings["chunk_size"], settings["chunk_retry_deadline"])
+	}
+	if md, _ := settings["metadata"].(map[string]any); md["team"] != "true" {
+		t.Errorf("metadata = %#v, want team as a string", settings["metadata"])
+	}
+}
+
+func TestFile_ProfileCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("profiles:\n  Work:\n    bucket: work-bucket\n"), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+
+	if got, ok := f.Get("work", "bucket"); !ok || got != "work-bucket" {
+		t.Errorf("Get(work, bucket) = %q, %v, want work-bucket", got, ok)
+	}
+	if err := f.Set("Cust", "bucket", "cust-bucket"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got, ok := f.Get("cust", "bucket"); !ok || got != "cust-bucket" {
+		t.Errorf("Get(cust, bucket) = %q, %v, want cust-bucket", got, ok)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "  cust:\n    bucket: cust-bucket") {
+		t.Errorf("config file = %q, want the profile name lowercased", data)
+	}
+}
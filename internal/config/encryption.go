@@ -0,0 +1,63 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// EncryptionKeySize is the size of a customer-supplied encryption key (AES-256).
+const EncryptionKeySize = 32
+
+// HasEncryptionKey reports whether a customer-supplied encryption key is configured.
+func (c *Config) HasEncryptionKey() bool {
+	return c.EncryptionKeyFile != "" || c.EncryptionKeyCommand != ""
+}
+
+// EncryptionKey returns the customer-supplied encryption key from
+// EncryptionKeyFile or, if that is empty, from the output of
+// EncryptionKeyCommand. The key may be raw 32 bytes or base64 encoded.
+// It returns nil when neither is set.
+func (c *Config) EncryptionKey(ctx context.Context) ([]byte, error) {
+	switch {
+	case c.EncryptionKeyFile != "":
+		data, err := os.ReadFile(c.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption_key_file: %w", err)
+		}
+		key, err := decodeEncryptionKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption_key_file %s: %w", c.EncryptionKeyFile, err)
+		}
+		return key, nil
+	case c.EncryptionKeyCommand != "":
+		out, err := runSecretCommand(ctx, "encryption_key_command", c.EncryptionKeyCommand)
+		if err != nil {
+			return nil, err
+		}
+		key, err := decodeEncryptionKey(out)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output from encryption_key_command: %w", err)
+		}
+		return key, nil
+	}
+	return nil, nil
+}
+
+// decodeEncryptionKey accepts a raw or base64 encoded AES-256 key.
+func decodeEncryptionKey(data []byte) ([]byte, error) {
+	if len(data) == EncryptionKeySize {
+		return data, nil
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == EncryptionKeySize {
+		return trimmed, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(string(trimmed))
+	if err != nil || len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("must be %d raw bytes or their base64 encoding", EncryptionKeySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte{0xab}, EncryptionKeySize)
+
+func TestConfig_EncryptionKey(t *testing.T) {
+	dir := t.TempDir()
+	writeKey := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("failed to write key file: %v", err)
+		}
+		return path
+	}
+	b64 := base64.StdEncoding.EncodeToString(testEncryptionKey)
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "none",
+			cfg:  Config{},
+			want: nil,
+		},
+		{
+			name: "raw file",
+			cfg:  Config{EncryptionKeyFile: writeKey("raw.key", testEncryptionKey)},
+			want: testEncryptionKey,
+		},
+		{
+			name: "base64 file",
+			cfg:  Config{EncryptionKeyFile: writeKey("b64.key", []byte(b64+"\n"))},
+			want: testEncryptionKey,
+		},
+		{
+			name:    "short key",
+			cfg:     Config{EncryptionKeyFile: writeKey("short.key", []byte("too short"))},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			cfg:     Config{EncryptionKeyFile: filepath.Join(dir, "missing.key")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.EncryptionKey(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EncryptionKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("EncryptionKey() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_EncryptionKey_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell")
+	}
+
+	b64 := base64.StdEncoding.EncodeToString(testEncryptionKey)
+	cfg := Config{EncryptionKeyCommand: "echo " + b64}
+	if !cfg.HasEncryptionKey() {
+		t.Fatal("HasEncryptionKey() = false, want true")
+	}
+	got, err := cfg.EncryptionKey(context.Background())
+	if err != nil {
+		t.Fatalf("EncryptionKey() error = %v", err)
+	}
+	if !bytes.Equal(got, testEncryptionKey) {
+		t.Errorf("EncryptionKey() = %x, want %x", got, testEncryptionKey)
+	}
+
+	cfg = Config{EncryptionKeyCommand: "exit 1"}
+	if _, err := cfg.EncryptionKey(context.Background()); err == nil {
+		t.Error("EncryptionKey() should fail when the command fails")
+	}
+}
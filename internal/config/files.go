@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// UserConfigFilename is the name of the user config file.
+	UserConfigFilename = "config.yaml"
+
+	// ProjectConfigFilename is the name of the project config file, searched
+	// upward from the working directory.
+	ProjectConfigFilename = ".reprint.yaml"
+)
+
+// configDir returns the base config directory, honoring XDG_CONFIG_HOME.
+// Returns empty string if it cannot be determined.
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config")
+}
+
+// CacheDir returns the reprint cache directory
+// ($XDG_CACHE_HOME/reprint or ~/.cache/reprint).
+// Returns empty string if the home directory cannot be determined.
+func CacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "reprint")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cache", "reprint")
+}
+
+// StateDir returns the reprint state directory
+// ($XDG_STATE_HOME/reprint or ~/.local/state/reprint), for data that must
+// survive cleaning the cache.
+// Returns empty string if the home directory cannot be determined.
+func StateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "reprint")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", "reprint")
+}
+
+// UserConfigPath returns the path of the user config file.
+// Returns empty string if the config directory cannot be determined.
+func UserConfigPath() string {
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "reprint", UserConfigFilename)
+}
+
+// EditablePath returns the config file that edits should target: explicit,
+// then REPRINT_CONFIG, then the user config file.
+func EditablePath(explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if path := os.Getenv("REPRINT_CONFIG"); path != "" {
+		return path
+	}
+	return UserConfigPath()
+}
+
+// FindProjectConfig searches upward from dir for a project config file.
+// Returns empty string if none is found.
+func FindProjectConfig(dir string) string {
+	for {
+		path := filepath.Join(dir, ProjectConfigFilename)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// findConfigFiles returns the config files to load, lowest priority first,
+// and the project config file among them, if any. explicit replaces the user
+// config file and must exist.
+func findConfigFiles(explicit string) ([]string, string, error) {
+	var files []string
+
+	if explicit == "" {
+		explicit = os.Getenv("REPRINT_CONFIG")
+	}
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return nil, "", fmt.Errorf("config file %s: %w", explicit, err)
+		}
+		files = append(files, explicit)
+	} else if path := UserConfigPath(); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, "", fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	var project string
+	if wd, err := os.Getwd(); err == nil {
+		if path := FindProjectConfig(wd); path != "" && !containsPath(files, path) {
+			files = append(files, path)
+			project = path
+		}
+	}
+
+	return files, project, nil
+}
+
+// readConfigFile reads and validates a YAML config file. A project config
+// file comes with the repository of decks, so it may not set UserOnlyKeys.
+func readConfigFile(path string, project bool) (layer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return layer{}, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	settings, lines, err := parseConfigFile(path, data)
+	if err == nil && project {
+		err = checkProjectSettings(path, settings, lines)
+	}
+	if err != nil {
+		return layer{}, fmt.Errorf("invalid config file:\n%w", err)
+	}
+	return layer{path: path, settings: settings, lines: lines}, nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if a, err := filepath.Abs(p); err == nil && a == path {
+			return true
+		}
+	}
+	return false
+}
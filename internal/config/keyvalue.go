@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// ParseKeyValues parses "key=value" pairs into a map.
+func ParseKeyValues(pairs []string) (map[string]string, error) {
+	m := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid key=value pair %q", pair)
+		}
+		m[k] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
+
+// isMapKey reports whether the setting key is a map setting.
+func isMapKey(key string) bool {
+	field, ok := settingField(key)
+	return ok && field.Type.Kind() == reflect.Map
+}
+
+// applyMapEnv merges map settings from "k=v,k=v" environment variables over
+// the values from config files.
+func (c *Config) applyMapEnv() error {
+	v := reflect.ValueOf(c).Elem()
+	for _, key := range Keys() {
+		env := os.Getenv(EnvName(key))
+		if env == "" || !isMapKey(key) {
+			continue
+		}
+		pairs, err := ParseKeyValues(strings.Split(env, ","))
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvName(key), err)
+		}
+
+		field, _ := settingField(key)
+		m := v.FieldByIndex(field.Index)
+		if m.IsNil() {
+			m.Set(reflect.MakeMap(field.Type))
+		}
+		for k, val := range pairs {
+			m.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(val))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	got, err := ParseKeyValues([]string{"team=sales", " cost-center = 1234 ", "empty="})
+	if err != nil {
+		t.Fatalf("ParseKeyValues() error = %v", err)
+	}
+	want := map[string]string{"team": "sales", "cost-center": "1234", "empty": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseKeyValues() = %v, want %v", got, want)
+	}
+
+	for _, pair := range []string{"team", "=sales"} {
+		if _, err := ParseKeyValues([]string{pair}); err == nil {
+			t.Errorf("ParseKeyValues(%q) should fail", pair)
+		}
+	}
+}
+
+func TestLoad_Metadata(t *testing.T) {
+	t.Chdir(t.TempDir())
+	home := t.TempDir()
+	writeConfigFile(t, home, "metadata:\n  team: sales\n  owner: alice\n")
+	t.Setenv("HOME", home)
+	t.Setenv("REPRINT_METADATA", "owner=bob,env=ci")
+
+	cfg, err := Load(WithMetadata(map[string]string{"env": "local"}))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := map[string]string{"team": "sales", "owner": "bob", "env": "local"}
+	if !reflect.DeepEqual(cfg.Metadata, want) {
+		t.Errorf("Metadata = %v, want %v", cfg.Metadata, want)
+	}
+	if got := cfg.Source("metadata"); got.Kind != SourceFlag {
+		t.Errorf("Source(metadata) = %v, want flag", got)
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Log levels accepted by ParseLogLevel.
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// Log formats accepted by ValidateLogFormat.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// ParseLogLevel parses a log level. Empty means warn, which keeps the output
+// of successful runs unchanged.
+func ParseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case LogLevelDebug:
+		return slog.LevelDebug, nil
+	case LogLevelInfo:
+		return slog.LevelInfo, nil
+	case "", LogLevelWarn, "warning":
+		return slog.LevelWarn, nil
+	case LogLevelError:
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q: must be %s, %s, %s or %s", level, LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError)
+}
+
+// ValidateLogFormat checks that format is a supported log format. Empty
+// means text and is valid.
+func ValidateLogFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "", LogFormatText, LogFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("invalid log format %q: must be %s or %s", format, LogFormatText, LogFormatJSON)
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{level: "", want: slog.LevelWarn},
+		{level: "debug", want: slog.LevelDebug},
+		{level: "INFO", want: slog.LevelInfo},
+		{level: "warning", want: slog.LevelWarn},
+		{level: "error", want: slog.LevelError},
+		{level: "trace", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			got, err := ParseLogLevel(tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLogLevel(%q) error = %v, wantErr %v", tt.level, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLogLevel(%q) = %v, want %v", tt.level, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_LogSettings(t *testing.T) {
+	t.Setenv("REPRINT_LOG_LEVEL", "info")
+	t.Setenv("REPRINT_LOG_FORMAT", "json")
+
+	cfg, err := Load(WithVerbose(true))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.LogLevel != LogLevelDebug || cfg.Source("log_level").Name != "--verbose" {
+		t.Errorf("LogLevel = %q from %v, want debug from --verbose", cfg.LogLevel, cfg.Source("log_level"))
+	}
+	if cfg.LogFormat != LogFormatJSON {
+		t.Errorf("LogFormat = %q, want %q", cfg.LogFormat, LogFormatJSON)
+	}
+}
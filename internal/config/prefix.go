@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// PrefixVars holds the variables available in prefix templates.
+type PrefixVars struct {
+	Date     string // YYYY-MM-DD
+	Time     string // hhmmss
+	Year     string
+	Month    string
+	Day      string
+	Hostname string // short host name
+	User     string // OS user name
+	Deck     string // deck name from --deck or REPRINT_DECK
+	Profile  string // selected profile name
+}
+
+// NewPrefixVars returns the prefix template variables for the current host and user.
+func NewPrefixVars(now time.Time, deck, profile string) PrefixVars {
+	vars := PrefixVars{
+		Date:    now.Format("2006-01-02"),
+		Time:    now.Format("150405"),
+		Year:    now.Format("2006"),
+		Month:   now.Format("01"),
+		Day:     now.Format("02"),
+		Deck:    sanitizePathSegment(DeckName(deck)),
+		Profile: sanitizePathSegment(profile),
+	}
+	if host, err := os.Hostname(); err == nil {
+		host, _, _ = strings.Cut(host, ".")
+		vars.Hostname = sanitizePathSegment(host)
+	}
+	if u, err := user.Current(); err == nil {
+		name := u.Username
+		// Windows user names are qualified with the domain (DOMAIN\user).
+		if i := strings.LastIndex(name, `\`); i >= 0 {
+			name = name[i+1:]
+		}
+		vars.User = sanitizePathSegment(name)
+	}
+	return vars
+}
+
+// DeckName returns the deck name for a deck file path or name: the base name
+// without the Markdown extension.
+func DeckName(deck string) string {
+	if deck == "" {
+		return ""
+	}
+	name := filepath.Base(deck)
+	for _, ext := range []string{".md", ".markdown"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+// IsPrefixTemplate reports whether prefix contains template actions.
+func IsPrefixTemplate(prefix string) bool {
+	return strings.Contains(prefix, "{{")
+}
+
+// StaticPrefix returns the leading part of a prefix template that does not
+// depend on any variable, up to the last slash. It is the longest prefix all
+// rendered prefixes share.
+func StaticPrefix(prefix string) string {
+	static, _, _ := strings.Cut(prefix, "{{")
+	if i := strings.LastIndex(static, "/"); i >= 0 {
+		return static[:i+1]
+	}
+	return ""
+}
+
+// RenderPrefix renders a prefix template such as "deck/{{.User}}/{{.Date}}/".
+// A prefix without template actions is returned as is. Referencing a variable
+// that is empty (e.g., {{.Deck}} without a deck name) is an error.
+func RenderPrefix(prefix string, vars PrefixVars) (string, error) {
+	if !IsPrefixTemplate(prefix) {
+		return prefix, nil
+	}
+
+	tmpl, err := template.New("prefix").Option("missingkey=error").Parse(prefix)
+	if err != nil {
+		return "", fmt.Errorf("invalid prefix template %q: %w", prefix, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars.values()); err != nil {
+		return "", fmt.Errorf("cannot render prefix template %q (unknown or empty variable; is --deck or REPRINT_DECK set?): %w", prefix, err)
+	}
+
+	rendered := buf.String()
+	if strings.HasPrefix(rendered, "/") || strings.Contains(rendered, "//") {
+		return "", fmt.Errorf("prefix template %q renders to %q, which has an empty path segment", prefix, rendered)
+	}
+	return rendered, nil
+}
+
+// values returns the non-empty variables keyed by name.
+func (v PrefixVars) values() map[string]string {
+	values := make(map[string]string)
+	rv := reflect.ValueOf(v)
+	for i := 0; i < rv.NumField(); i++ {
+		if s := rv.Field(i).String(); s != "" {
+			values[rv.Type().Field(i).Name] = s
+		}
+	}
+	return values
+}
+
+var unsafeSegmentChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sanitizePathSegment replaces characters that are unsafe or discouraged in
+// GCS object names, including slashes, so a value stays one path segment.
+func sanitizePathSegment(s string) string {
+	s = unsafeSegmentChars.ReplaceAllString(s, "-")
+	s = strings.Trim(s, "-")
+	if s == "." || s == ".." {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderPrefix(t *testing.T) {
+	vars := PrefixVars{
+		Date:     "2024-03-05",
+		Time:     "093000",
+		Year:     "2024",
+		Month:    "03",
+		Day:      "05",
+		Hostname: "laptop",
+		User:     "alice",
+		Deck:     "quarterly-review",
+		Profile:  "customer",
+	}
+
+	tests := []struct {
+		name    string
+		prefix  string
+		vars    PrefixVars
+		want    string
+		wantErr bool
+	}{
+		{name: "static", prefix: "deck/", vars: vars, want: "deck/"},
+		{name: "user and date", prefix: "deck/{{.User}}/{{.Date}}/", vars: vars, want: "deck/alice/2024-03-05/"},
+		{name: "all variables", prefix: "{{.Profile}}/{{.Hostname}}/{{.Deck}}/{{.Year}}{{.Month}}{{.Day}}-{{.Time}}/", vars: vars, want: "customer/laptop/quarterly-review/20240305-093000/"},
+		{name: "unknown variable", prefix: "deck/{{.Team}}/", vars: vars, wantErr: true},
+		{name: "syntax error", prefix: "deck/{{.User/", vars: vars, wantErr: true},
+		{name: "empty deck", prefix: "deck/{{.Deck}}/", vars: PrefixVars{}, wantErr: true},
+		{name: "empty leading segment", prefix: "{{.Deck}}/images/", vars: PrefixVars{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderPrefix(tt.prefix, tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderPrefix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RenderPrefix() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPrefixVars(t *testing.T) {
+	now := time.Date(2024, 3, 5, 9, 30, 0, 0, time.UTC)
+	vars := NewPrefixVars(now, "slides/Quarterly Review.md", "customer")
+
+	if vars.Date != "2024-03-05" {
+		t.Errorf("Date = %q, want %q", vars.Date, "2024-03-05")
+	}
+	if vars.Time != "093000" {
+		t.Errorf("Time = %q, want %q", vars.Time, "093000")
+	}
+	if vars.Deck != "Quarterly-Review" {
+		t.Errorf("Deck = %q, want %q", vars.Deck, "Quarterly-Review")
+	}
+	if vars.Profile != "customer" {
+		t.Errorf("Profile = %q, want %q", vars.Profile, "customer")
+	}
+}
+
+func TestLoad_PrefixTemplate(t *testing.T) {
+	t.Setenv("REPRINT_PREFIX", "deck/{{.Deck}}")
+	t.Setenv("REPRINT_DECK", "")
+
+	cfg, err := Load(WithDeck("intro.md"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Prefix != "deck/intro/" {
+		t.Errorf("Prefix = %q, want %q", cfg.Prefix, "deck/intro/")
+	}
+
+	// Commands other than uploads do not need the rendered prefix.
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() without a deck error = %v", err)
+	}
+	if _, err := cfg.RenderedPrefix(); err == nil {
+		t.Error("RenderedPrefix() should fail when the template renders an empty deck name")
+	}
+	if cfg.PrefixTemplate != "deck/{{.Deck}}" {
+		t.Errorf("PrefixTemplate = %q, want the template", cfg.PrefixTemplate)
+	}
+
+	if cfg, err := Load(WithDefaultDeck("placeholder")); err != nil || cfg.Prefix != "deck/placeholder/" {
+		t.Errorf("Load(WithDefaultDeck()) = %v, %v, want prefix deck/placeholder/", cfg, err)
+	}
+	if cfg, err := Load(WithDeck("intro.md"), WithDefaultDeck("placeholder")); err != nil || cfg.Prefix != "deck/intro/" {
+		t.Errorf("Load(WithDeck(), WithDefaultDeck()) = %v, %v, want prefix deck/intro/", cfg, err)
+	}
+}
+
+func TestStaticPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "deck/", want: "deck/"},
+		{prefix: "deck/{{.User}}/{{.Date}}/", want: "deck/"},
+		{prefix: "deck/user-{{.User}}/", want: "deck/"},
+		{prefix: "{{.User}}/", want: ""},
+		{prefix: "team/deck/{{.Date}}", want: "team/deck/"},
+	}
+
+	for _, tt := range tests {
+		if got := StaticPrefix(tt.prefix); got != tt.want {
+			t.Errorf("StaticPrefix(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileKeys are keys that are only meaningful at the top level of a config file.
+var fileKeys = []string{"default_profile", "profiles"}
+
+// UserOnlyKeys are the settings that a project config file may not set,
+// since they run commands or read and write local files: anyone who can
+// commit to a repository of decks could otherwise make reprint run their
+// commands. They are taken from the user config file, the environment, and
+// flags only.
+var UserOnlyKeys = []string{"credentials", "credentials_command", "encryption_key_file", "encryption_key_command", "audit_log"}
+
+// checkProjectSettings rejects UserOnlyKeys in the settings of a project
+// config file, including its profiles.
+func checkProjectSettings(path string, settings map[string]any, lines map[string]int) error {
+	var errs []error
+	reject := func(key, line string) {
+		errs = append(errs, fmt.Errorf("%s:%d: %s is not allowed in a project config file; set it in the user config file or %s", path, lines[line], key, EnvName(key)))
+	}
+	for _, key := range UserOnlyKeys {
+		if _, ok := settings[key]; ok {
+			reject(key, key)
+		}
+		profiles, _ := settings["profiles"].(map[string]any)
+		for _, name := range slices.Sorted(maps.Keys(profiles)) {
+			if p, _ := profiles[name].(map[string]any); p != nil {
+				if _, ok := p[key]; ok {
+					reject(key, "profiles."+name+"."+key)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseConfigFile validates a YAML config file against the Config schema and
+// returns its settings together with the line number of each setting.
+// Line numbers are keyed by setting key, or "profiles.<name>.<key>" for
+// settings inside a profile.
+func parseConfigFile(path string, data []byte) (map[string]any, map[string]int, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	settings := make(map[string]any)
+	lines := make(map[string]int)
+	if len(doc.Content) == 0 {
+		return settings, lines, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s:%d: top level must be a mapping", path, root.Line)
+	}
+
+	var errs []error
+	errorf := func(node *yaml.Node, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s:%d: %s", path, node.Line, fmt.Sprintf(format, args...)))
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, valueNode := root.Content[i], root.Content[i+1]
+		key := strings.ToLower(keyNode.Value)
+
+		switch key {
+		case "default_profile":
+			if valueNode.Kind != yaml.ScalarNode {
+				errorf(valueNode, "default_profile must be a string")
+				continue
+			}
+			settings[key] = valueNode.Value
+			lines[key] = keyNode.Line
+		case "profiles":
+			profiles, profileLines, profileErrs := parseProfiles(path, valueNode)
+			errs = append(errs, profileErrs...)
+			settings[key] = profiles
+			for k, line := range profileLines {
+				lines["profiles."+k] = line
+			}
+		default:
+			value, err := parseSetting(keyNode, valueNode, fileKeys...)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s:%d: %w", path, keyNode.Line, err))
+				continue
+			}
+			settings[key] = value
+			lines[key] = keyNode.Line
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return settings, lines, nil
+}
+
+// parseProfiles validates the profiles mapping.
+func parseProfiles(path string, node *yaml.Node) (map[string]any, map[string]int, []error) {
+	profiles := make(map[string]any)
+	lines := make(map[string]int)
+	if node.Kind != yaml.MappingNode {
+		return profiles, lines, []error{fmt.Errorf("%s:%d: profiles must be a mapping of profile names to settings", path, node.Line)}
+	}
+
+	var errs []error
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		nameNode, profileNode := node.Content[i], node.Content[i+1]
+		name := strings.ToLower(nameNode.Value)
+		settings := make(map[string]any)
+		profiles[name] = settings
+
+		if profileNode.Kind == yaml.ScalarNode && profileNode.Tag == "!!null" {
+			continue
+		}
+		if profileNode.Kind != yaml.MappingNode {
+			errs = append(errs, fmt.Errorf("%s:%d: profile %q must be a mapping", path, profileNode.Line, nameNode.Value))
+			continue
+		}
+		for j := 0; j+1 < len(profileNode.Content); j += 2 {
+			keyNode, valueNode := profileNode.Content[j], profileNode.Content[j+1]
+			key := strings.ToLower(keyNode.Value)
+			value, err := parseSetting(keyNode, valueNode)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s:%d: profile %q: %w", path, keyNode.Line, nameNode.Value, err))
+				continue
+			}
+			settings[key] = value
+			lines[name+"."+key] = keyNode.Line
+		}
+	}
+	return profiles, lines, errs
+}
+
+// parseSetting validates a single setting against the Config field it maps to.
+// extra lists additional valid keys used for suggestions.
+func parseSetting(keyNode, valueNode *yaml.Node, extra ...string) (any, error) {
+	key := strings.ToLower(keyNode.Value)
+	field, ok := settingField(key)
+	if !ok {
+		msg := fmt.Sprintf("unknown key %q", keyNode.Value)
+		if s := suggest(key, append(Keys(), extra...)); s != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", s)
+		}
+		return nil, errors.New(msg)
+	}
+
+	switch field.Type.Kind() {
+	case reflect.Map:
+		if valueNode.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s must be a mapping", key)
+		}
+		m := make(map[string]any, len(valueNode.Content)/2)
+		for i := 0; i+1 < len(valueNode.Content); i += 2 {
+			if valueNode.Content[i+1].Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s.%s must be a string", key, valueNode.Content[i].Value)
+			}
+			m[valueNode.Content[i].Value] = valueNode.Content[i+1].Value
+		}
+		return m, nil
+	default:
+		if valueNode.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%s must be a single value", key)
+		}
+		var value any
+		if err := valueNode.Decode(&value); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return value, nil
+	}
+}
+
+// settingField returns the Config field for a setting key.
+func settingField(key string) (reflect.StructField, bool) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("mapstructure") == key {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// suggest returns the candidate closest to key, or empty string if none is
+// close enough to be a likely typo.
+func suggest(key string, candidates []string) string {
+	maxDist := 2
+	if len(key) > 8 {
+		maxDist = 3
+	}
+	best, bestDist := "", maxDist+1
+	for _, c := range candidates {
+		if d := levenshtein(key, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	content := `bucket: my-bucket
+prefix: deck/
+default_profile: customer
+profiles:
+  customer:
+    bucket: customer-bucket
+  empty:
+`
+	settings, lines, err := parseConfigFile("config.yaml", []byte(content))
+	if err != nil {
+		t.Fatalf("parseConfigFile() error = %v", err)
+	}
+
+	if settings["bucket"] != "my-bucket" {
+		t.Errorf("bucket = %v, want %q", settings["bucket"], "my-bucket")
+	}
+	profiles, _ := settings["profiles"].(map[string]any)
+	customer, _ := profiles["customer"].(map[string]any)
+	if customer["bucket"] != "customer-bucket" {
+		t.Errorf("profiles.customer.bucket = %v, want %q", customer["bucket"], "customer-bucket")
+	}
+	if _, ok := profiles["empty"]; !ok {
+		t.Error("profiles.empty should be defined")
+	}
+	if lines["prefix"] != 2 {
+		t.Errorf("line of prefix = %d, want 2", lines["prefix"])
+	}
+	if lines["profiles.customer.bucket"] != 6 {
+		t.Errorf("line of profiles.customer.bucket = %d, want 6", lines["profiles.customer.bucket"])
+	}
+}
+
+func TestParseConfigFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "unknown key with suggestion",
+			content: "buket: my-bucket\n",
+			want:    []string{`config.yaml:1: unknown key "buket" (did you mean "bucket"?)`},
+		},
+		{
+			name:    "unknown key without suggestion",
+			content: "bucket: my-bucket\nregion: asia\n",
+			want:    []string{`config.yaml:2: unknown key "region"`},
+		},
+		{
+			name:    "unknown key in profile",
+			content: "profiles:\n  customer:\n    prefx: customer/\n",
+			want:    []string{`config.yaml:3: profile "customer": unknown key "prefx" (did you mean "prefix"?)`},
+		},
+		{
+			name:    "multiple errors",
+			content: "buket: a\ncredentails: b\n",
+			want:    []string{`config.yaml:1: unknown key "buket"`, `config.yaml:2: unknown key "credentails" (did you mean "credentials"?)`},
+		},
+		{
+			name:    "mapping for single value",
+			content: "bucket:\n  name: my-bucket\n",
+			want:    []string{"config.yaml:1: bucket must be a single value"},
+		},
+		{
+			name:    "profiles not a mapping",
+			content: "profiles: customer\n",
+			want:    []string{"config.yaml:1: profiles must be a mapping"},
+		},
+		{
+			name:    "syntax error",
+			content: "bucket: [\n",
+			want:    []string{"config.yaml:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseConfigFile("config.yaml", []byte(tt.content))
+			if err == nil {
+				t.Fatal("parseConfigFile() should fail")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error = %q, want to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "", want: ""},
+		{prefix: "deck/", want: "deck/"},
+		{prefix: "deck", want: "deck/"},
+		{prefix: "/deck", want: "deck/"},
+		{prefix: "team/deck", want: "team/deck/"},
+		{prefix: "/", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizePrefix(tt.prefix); got != tt.want {
+			t.Errorf("NormalizePrefix(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
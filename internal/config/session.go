@@ -0,0 +1,29 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// sessionPattern matches session ids, which become a segment of object names.
+var sessionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// NewSessionID returns a new session id: the current time, so ids sort by
+// start, and a random suffix, e.g. "20240305-093000-3f9a1c".
+func NewSessionID(now time.Time) string {
+	var b [3]byte
+	rand.Read(b[:])
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(b[:])
+}
+
+// ValidateSession checks that id can be used as a session id. Empty means no
+// session and is valid.
+func ValidateSession(id string) error {
+	if id == "" || sessionPattern.MatchString(id) {
+		return nil
+	}
+	return fmt.Errorf("invalid session %q: must be 1 to 64 letters, digits, '.', '_' or '-', starting with a letter or digit", id)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSessionID(t *testing.T) {
+	now := time.Date(2024, 3, 5, 9, 30, 0, 0, time.UTC)
+	a, b := NewSessionID(now), NewSessionID(now)
+	if a == b {
+		t.Errorf("NewSessionID() returned %q twice", a)
+	}
+	if len(a) != len("20240305-093000-3f9a1c") || a[:16] != "20240305-093000-" {
+		t.Errorf("NewSessionID() = %q, want 20240305-093000- and a random suffix", a)
+	}
+	if err := ValidateSession(a); err != nil {
+		t.Errorf("ValidateSession(NewSessionID()) error = %v", err)
+	}
+}
+
+func TestValidateSession(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{id: ""},
+		{id: "20240305-093000-3f9a1c"},
+		{id: "ci_run.42"},
+		{id: "..", wantErr: true},
+		{id: "-rf", wantErr: true},
+		{id: "a/b", wantErr: true},
+		{id: "a b", wantErr: true},
+		{id: strings.Repeat("a", 65), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateSession(tt.id); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateSession(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+		}
+	}
+}
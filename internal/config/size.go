@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps size suffixes to their multipliers. Binary suffixes are
+// powers of 1024, decimal suffixes powers of 1000.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// ParseSize parses a byte size such as "16777216", "16MiB" or "500KB".
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	num, unit := s, int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(strings.ToLower(s), strings.ToLower(u.suffix)) {
+			num, unit = strings.TrimSpace(s[:len(s)-len(u.suffix)]), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q: must be a non-negative number of bytes with an optional unit (e.g., 8MiB)", s)
+	}
+	return n * unit, nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "16777216", want: 16 << 20},
+		{in: "8MiB", want: 8 << 20},
+		{in: "256 KiB", want: 256 << 10},
+		{in: "1gib", want: 1 << 30},
+		{in: "500KB", want: 500 * 1000},
+		{in: "100B", want: 100},
+		{in: "", wantErr: true},
+		{in: "MiB", wantErr: true},
+		{in: "-1MiB", wantErr: true},
+		{in: "1.5MiB", wantErr: true},
+		{in: "8TB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_UploadSettings(t *testing.T) {
+	t.Chdir(t.TempDir())
+	home := t.TempDir()
+	writeConfigFile(t, home, "chunk_size: 8MiB\nchunk_retry_deadline: 2m\n")
+	t.Setenv("HOME", home)
+	t.Setenv("REPRINT_RESUMABLE", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ChunkSize != "8MiB" {
+		t.Errorf("ChunkSize = %q, want %q", cfg.ChunkSize, "8MiB")
+	}
+	if cfg.ChunkRetryDeadline != 2*time.Minute {
+		t.Errorf("ChunkRetryDeadline = %v, want %v", cfg.ChunkRetryDeadline, 2*time.Minute)
+	}
+	if !cfg.Resumable {
+		t.Error("Resumable = false, want true")
+	}
+	if got, _ := cfg.Value("chunk_retry_deadline"); got != "2m0s" {
+		t.Errorf("Value(chunk_retry_deadline) = %q, want %q", got, "2m0s")
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SourceKind identifies the kind of place a setting came from.
+type SourceKind int
+
+const (
+	// SourceDefault means the setting was not configured anywhere.
+	SourceDefault SourceKind = iota
+	// SourceFile means the setting came from a config file.
+	SourceFile
+	// SourceEnv means the setting came from an environment variable.
+	SourceEnv
+	// SourceFlag means the setting came from a CLI flag.
+	SourceFlag
+)
+
+// Source describes where the effective value of a setting came from.
+type Source struct {
+	Kind    SourceKind
+	Name    string // file path, environment variable, flag, or default path
+	Line    int    // line number in the file, for SourceFile
+	Profile string // profile the setting was read from, for SourceFile
+}
+
+// String returns a human readable description of the source.
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceFile:
+		name := s.Name
+		if s.Line > 0 {
+			name = fmt.Sprintf("%s:%d", s.Name, s.Line)
+		}
+		if s.Profile != "" {
+			return fmt.Sprintf("file %s (profile %s)", name, s.Profile)
+		}
+		return "file " + name
+	case SourceEnv:
+		return "env " + s.Name
+	case SourceFlag:
+		return "flag " + s.Name
+	default:
+		if s.Name != "" {
+			return "default " + s.Name
+		}
+		return "default"
+	}
+}
+
+// formatValue formats a setting value for display.
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, fmt.Sprint(k.Interface()))
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", k, v.MapIndex(reflect.ValueOf(k)).Interface()))
+		}
+		return strings.Join(pairs, ",")
+	case reflect.Slice:
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, fmt.Sprint(v.Index(i).Interface()))
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,298 @@
+// Package daemon implements a long-running reprint process that serves
+// requests on a Unix socket, so that callers can reuse its GCS client
+// instead of creating one per invocation.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/minodisk/reprint/internal/gcs"
+)
+
+// Operations of a Request.
+const (
+	OpPing   = "ping" // checks that the daemon serves the caller's configuration
+	OpUpload = "upload"
+	OpDelete = "delete"
+)
+
+// ErrNotRunning is returned by Call when no daemon listens on the socket.
+var ErrNotRunning = errors.New("daemon is not running")
+
+// Codes of a Response error, which identify the errors that callers check
+// for with errors.Is.
+const (
+	CodeObjectExists       = "object_exists"
+	CodeNotFound           = "not_found"
+	CodeGenerationMismatch = "generation_mismatch"
+)
+
+var codeErrors = map[string]error{
+	CodeObjectExists:       gcs.ErrObjectExists,
+	CodeNotFound:           storage.ErrObjectNotExist,
+	CodeGenerationMismatch: gcs.ErrGenerationMismatch,
+}
+
+// Request is a request to the daemon.
+type Request struct {
+	Op string `json:"op"`
+	// Fingerprint identifies the caller's effective configuration. The daemon
+	// only serves callers whose configuration matches its own.
+	Fingerprint string `json:"fingerprint"`
+	// Trace carries the caller's trace context, so that the daemon's spans
+	// join the caller's trace.
+	Trace map[string]string `json:"trace,omitempty"`
+	// Deck, Session and LogLevel are the caller's per-invocation settings,
+	// which are not part of the fingerprint.
+	Deck     string `json:"deck,omitempty"`
+	Session  string `json:"session,omitempty"`
+	LogLevel string `json:"log_level,omitempty"`
+
+	// Upload
+	ID   string `json:"id,omitempty"`
+	MIME string `json:"mime,omitempty"`
+	Data []byte `json:"data,omitempty"`
+
+	// Delete
+	IDs         []string `json:"ids,omitempty"`
+	Generation  int64    `json:"generation,omitempty"`
+	Concurrency int      `json:"concurrency,omitempty"`
+	// After delays the deletes, which the daemon then schedules.
+	After time.Duration `json:"after,omitempty"`
+}
+
+// Response is the daemon's response to a Request.
+type Response struct {
+	// Mismatch reports that the caller's configuration differs from the
+	// daemon's, so the caller should handle the request itself.
+	Mismatch bool   `json:"mismatch,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Code identifies the kind of Error, if known.
+	Code string `json:"code,omitempty"`
+
+	Upload  *gcs.UploadResult `json:"upload,omitempty"`
+	Deletes []DeleteResult    `json:"deletes,omitempty"`
+}
+
+// ErrorResponse returns a response reporting err, with the code of its kind.
+func ErrorResponse(err error) *Response {
+	resp := &Response{Error: err.Error()}
+	for code, target := range codeErrors {
+		if errors.Is(err, target) {
+			resp.Code = code
+			break
+		}
+	}
+	return resp
+}
+
+// Err returns the error reported by the response, or nil. It matches the
+// error of the response's code with errors.Is, like the daemon's error did.
+func (r *Response) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	return &remoteError{msg: r.Error, err: codeErrors[r.Code]}
+}
+
+// remoteError is an error returned by the daemon.
+type remoteError struct {
+	msg string
+	err error
+}
+
+func (e *remoteError) Error() string { return e.msg }
+func (e *remoteError) Unwrap() error { return e.err }
+
+// DeleteResult is the outcome of deleting one object.
+type DeleteResult struct {
+	ID       string `json:"id"`
+	NotFound bool   `json:"not_found,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Handler serves a request.
+type Handler func(ctx context.Context, req *Request) *Response
+
+// Server serves requests on a listener until its context is canceled or it
+// has been idle for IdleTimeout.
+type Server struct {
+	Handler     Handler
+	IdleTimeout time.Duration
+
+	mu      sync.Mutex
+	active  int         // requests in flight and functions scheduled with After
+	idle    *time.Timer // shuts the server down when nothing is active
+	closed  bool
+	timers  map[*time.Timer]struct{}
+	dropped int
+}
+
+// Serve accepts connections on l, one request per connection. It returns nil
+// when ctx is canceled or the server shuts down after being idle; requests in
+// flight are completed first, but functions scheduled with After that have
+// not run yet are dropped.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	shutdown := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.closed {
+			s.closed = true
+			l.Close()
+		}
+	}
+
+	s.mu.Lock()
+	s.idle = time.AfterFunc(s.IdleTimeout, func() {
+		s.mu.Lock()
+		busy := s.active > 0
+		s.mu.Unlock()
+		if !busy {
+			shutdown()
+		}
+	})
+	s.mu.Unlock()
+	defer s.stop()
+
+	stop := context.AfterFunc(ctx, shutdown)
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.begin()
+		s.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+
+			s.mu.Lock()
+			s.end()
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// After runs fn after d. The server does not shut down for being idle while
+// fn is pending.
+func (s *Server) After(d time.Duration, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timers == nil {
+		s.timers = make(map[*time.Timer]struct{})
+	}
+	s.begin()
+
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		s.mu.Lock()
+		_, pending := s.timers[t]
+		delete(s.timers, t)
+		s.mu.Unlock()
+		if !pending {
+			return
+		}
+
+		fn()
+
+		s.mu.Lock()
+		s.end()
+		s.mu.Unlock()
+	})
+	s.timers[t] = struct{}{}
+}
+
+// Dropped returns the number of functions scheduled with After that were
+// dropped because Serve returned first.
+func (s *Server) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// begin marks the start of activity. The caller holds s.mu.
+func (s *Server) begin() {
+	s.active++
+	if s.idle != nil {
+		s.idle.Stop()
+	}
+}
+
+// end marks the end of activity, starting the idle timeout when nothing else
+// is active. The caller holds s.mu.
+func (s *Server) end() {
+	s.active--
+	if s.active == 0 && s.idle != nil && !s.closed {
+		s.idle.Reset(s.IdleTimeout)
+	}
+}
+
+// stop drops pending functions when Serve returns.
+func (s *Server) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idle.Stop()
+	for t := range s.timers {
+		if t.Stop() {
+			s.dropped++
+		}
+		delete(s.timers, t)
+	}
+}
+
+// serveConn reads a request from conn and writes the response.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(&Response{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	json.NewEncoder(conn).Encode(s.Handler(ctx, &req))
+}
+
+// Call sends a request to the daemon listening on the socket at path.
+// It returns ErrNotRunning if there is no daemon to connect to.
+func Call(ctx context.Context, path string, req *Request) (*Response, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request to daemon: %w", err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response from daemon: %w", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,199 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/minodisk/reprint/internal/gcs"
+)
+
+// startServer serves s on a socket in a temporary directory and returns the
+// socket path, a function that cancels serving, and a channel receiving the
+// result of Serve.
+func startServer(t *testing.T, s *Server) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "d.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("Unix sockets are not supported: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, l) }()
+	return path, cancel, done
+}
+
+func TestCall(t *testing.T) {
+	path, _, _ := startServer(t, &Server{IdleTimeout: time.Minute, Handler: func(ctx context.Context, req *Request) *Response {
+		if req.Op != OpUpload || req.Fingerprint != "abc" {
+			return &Response{Mismatch: true}
+		}
+		return &Response{Upload: &gcs.UploadResult{ID: req.ID, URL: "https://example.com/" + req.ID, Generation: int64(len(req.Data))}}
+	}})
+
+	ctx := context.Background()
+	resp, err := Call(ctx, path, &Request{Op: OpUpload, Fingerprint: "abc", ID: "file-1", MIME: "image/png", Data: []byte("image")})
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if resp.Upload == nil || resp.Upload.ID != "file-1" || resp.Upload.Generation != 5 {
+		t.Errorf("Call() upload = %+v, want file-1 with generation 5", resp.Upload)
+	}
+
+	resp, err = Call(ctx, path, &Request{Op: OpUpload, Fingerprint: "other"})
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if !resp.Mismatch {
+		t.Error("Call() with another fingerprint should report a mismatch")
+	}
+}
+
+func TestCall_NotRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d.sock")
+	_, err := Call(context.Background(), path, &Request{Op: OpPing})
+	if !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Call() error = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestCall_Error(t *testing.T) {
+	path, _, _ := startServer(t, &Server{IdleTimeout: time.Minute, Handler: func(ctx context.Context, req *Request) *Response {
+		switch req.ID {
+		case "exists":
+			return ErrorResponse(fmt.Errorf("failed to upload %q: %w", req.ID, gcs.ErrObjectExists))
+		case "missing":
+			return ErrorResponse(fmt.Errorf("failed to delete %q: %w", req.ID, storage.ErrObjectNotExist))
+		}
+		return ErrorResponse(errors.New("boom"))
+	}})
+
+	for _, tt := range []struct {
+		id   string
+		want error
+	}{
+		{"exists", gcs.ErrObjectExists},
+		{"missing", storage.ErrObjectNotExist},
+		{"other", nil},
+	} {
+		resp, err := Call(context.Background(), path, &Request{Op: OpUpload, ID: tt.id})
+		if err != nil {
+			t.Fatalf("Call() error = %v", err)
+		}
+		err = resp.Err()
+		if err == nil {
+			t.Fatalf("Err() for %s = nil", tt.id)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("Err() for %s = %v, want %v", tt.id, err, tt.want)
+		}
+		for _, other := range codeErrors {
+			if other != tt.want && errors.Is(err, other) {
+				t.Errorf("Err() for %s matches %v", tt.id, other)
+			}
+		}
+	}
+
+	if err := (&Response{}).Err(); err != nil {
+		t.Errorf("Err() without error = %v", err)
+	}
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	const idleTimeout = 200 * time.Millisecond
+	started, release := make(chan struct{}), make(chan struct{})
+	path, _, done := startServer(t, &Server{IdleTimeout: idleTimeout, Handler: func(ctx context.Context, req *Request) *Response {
+		close(started)
+		<-release
+		return &Response{}
+	}})
+
+	// A request in flight keeps the server alive past the idle timeout.
+	called := make(chan error, 1)
+	go func() {
+		_, err := Call(context.Background(), path, &Request{Op: OpDelete})
+		called <- err
+	}()
+	<-started
+	select {
+	case err := <-done:
+		t.Fatalf("Serve() returned %v while a request is in flight", err)
+	case <-time.After(2 * idleTimeout):
+	}
+
+	close(release)
+	if err := <-called; err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve() did not return after the idle timeout")
+	}
+
+	if _, err := Call(context.Background(), path, &Request{Op: OpPing}); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Call() after shutdown error = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestServer_After(t *testing.T) {
+	const idleTimeout = 50 * time.Millisecond
+	ran := make(chan struct{})
+	s := &Server{IdleTimeout: idleTimeout}
+	s.Handler = func(ctx context.Context, req *Request) *Response {
+		s.After(req.After, func() { close(ran) })
+		return &Response{}
+	}
+	path, _, done := startServer(t, s)
+
+	if _, err := Call(context.Background(), path, &Request{Op: OpDelete, After: 4 * idleTimeout}); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	// The scheduled function keeps the server alive past the idle timeout.
+	select {
+	case <-ran:
+	case err := <-done:
+		t.Fatalf("Serve() returned %v before the scheduled function ran", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduled function did not run")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve() did not return after the idle timeout")
+	}
+	if n := s.Dropped(); n != 0 {
+		t.Errorf("Dropped() = %d, want 0", n)
+	}
+}
+
+func TestServer_After_DroppedOnShutdown(t *testing.T) {
+	s := &Server{IdleTimeout: time.Minute}
+	s.Handler = func(ctx context.Context, req *Request) *Response {
+		s.After(time.Hour, func() { t.Error("dropped function ran") })
+		return &Response{}
+	}
+	path, cancel, done := startServer(t, s)
+
+	if _, err := Call(context.Background(), path, &Request{Op: OpDelete}); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	if n := s.Dropped(); n != 1 {
+		t.Errorf("Dropped() = %d, want 1", n)
+	}
+}
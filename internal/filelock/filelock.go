@@ -0,0 +1,23 @@
+// Package filelock provides advisory locks on files shared by concurrent
+// reprint processes.
+package filelock
+
+import "os"
+
+// Lock blocks until it holds a lock on the file at path, creating it if
+// needed, exclusively for writers and shared for readers. It returns a
+// function that releases the lock.
+func Lock(path string, exclusive bool) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,44 @@
+package filelock
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock_Exclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	unlock, err := Lock(path, false)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	// Shared locks do not block each other.
+	unlockShared, err := Lock(path, false)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	unlockShared()
+
+	locked := make(chan func())
+	go func() {
+		unlock, err := Lock(path, true)
+		if err != nil {
+			t.Errorf("Lock() error = %v", err)
+		}
+		locked <- unlock
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("exclusive Lock() should wait for the shared lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case unlock := <-locked:
+		unlock()
+	case <-time.After(5 * time.Second):
+		t.Fatal("exclusive Lock() should succeed once the shared lock is released")
+	}
+}
@@ -0,0 +1,66 @@
+package gcs
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/minodisk/reprint/internal/audit"
+)
+
+// record appends r, completed with the outcome of err, to the audit log if
+// there is one. The operation is already done, so failing to record it is
+// logged rather than returned.
+func (c *Client) record(ctx context.Context, r audit.Record, err error) {
+	if c.auditLog == nil {
+		return
+	}
+	r.Time = time.Now()
+	r.Actor = c.actor
+	r.Bucket = c.bucket
+	switch {
+	case err == nil:
+		if r.Outcome == "" {
+			r.Outcome = audit.OutcomeOK
+		}
+	case IsNotFound(err):
+		r.Outcome = audit.OutcomeNotFound
+	default:
+		r.Outcome = audit.OutcomeFailed
+		r.Error = err.Error()
+	}
+
+	if err := c.auditLog.Append(r); err != nil {
+		c.logger.WarnContext(ctx, "failed to write audit log", "path", c.auditLog.Path(), "error", err)
+	}
+}
+
+// serviceAccountEmail returns the email of the service account whose key is
+// in credentialsJSON or the credentials file, or "" if there is none.
+func serviceAccountEmail(credentialsJSON []byte, credentials string) string {
+	key, err := loadSigningKey(credentialsJSON, credentials)
+	if err != nil || key == nil {
+		return ""
+	}
+	return key.email
+}
+
+// signedURLExpiry returns when a V4 signed URL expires, or the zero time if
+// rawURL is not signed (e.g., a public URL of an emulator).
+func signedURLExpiry(rawURL string) time.Time {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}
+	}
+	q := u.Query()
+	signed, err := time.Parse("20060102T150405Z", q.Get("X-Goog-Date"))
+	if err != nil {
+		return time.Time{}
+	}
+	seconds, err := strconv.Atoi(q.Get("X-Goog-Expires"))
+	if err != nil {
+		return time.Time{}
+	}
+	return signed.Add(time.Duration(seconds) * time.Second)
+}
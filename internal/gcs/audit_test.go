@@ -0,0 +1,114 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minodisk/reprint/internal/audit"
+)
+
+func TestClient_Upload_Audit(t *testing.T) {
+	f := newFakeServer(t)
+	log := audit.New(filepath.Join(t.TempDir(), "audit.jsonl"), 0)
+	c := newTestClient(t, f, WithAuditLog(log))
+	c.actor = "uploader@project.iam.gserviceaccount.com"
+	content := []byte("image")
+	ctx := context.Background()
+
+	start := time.Now()
+	res, err := c.Upload(ctx, "file-1", bytes.NewReader(content), "image/png")
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	end := time.Now()
+	// The object exists now, so uploading it again fails.
+	if _, err := c.Upload(ctx, "file-1", bytes.NewReader(content), "image/png"); !errors.Is(err, ErrObjectExists) {
+		t.Fatalf("Upload() of an existing object error = %v, want ErrObjectExists", err)
+	}
+
+	got, err := log.Read(audit.Filter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Read() = %+v, want 2 records", got)
+	}
+
+	// The signed URL is dated and expires to the second, rounded down.
+	expires := got[0].URLExpires
+	if expires.Before(start.Add(DefaultSignedURLExpiration-2*time.Second)) || expires.After(end.Add(DefaultSignedURLExpiration)) {
+		t.Errorf("record of an upload expires at %v, want %v after the upload", expires, DefaultSignedURLExpiration)
+	}
+	want := audit.Record{
+		Time:       got[0].Time,
+		Op:         audit.OpUpload,
+		Actor:      "uploader@project.iam.gserviceaccount.com",
+		Bucket:     "test-bucket",
+		Object:     "prefix/file-1",
+		Generation: res.Generation,
+		Size:       int64(len(content)),
+		// sha256 of "image"
+		SHA256:     "6105d6cc76af400325e94d588ce511be5bfdbb73b437dc51eca43917d7a43e3d",
+		MIME:       "image/png",
+		URLExpires: expires,
+		Outcome:    audit.OutcomeOK,
+	}
+	if got[0] != want {
+		t.Errorf("record of an upload = %+v, want %+v", got[0], want)
+	}
+	if got[1].Object != "prefix/file-1" || got[1].Outcome != audit.OutcomeFailed || got[1].Error == "" || got[1].SHA256 != want.SHA256 {
+		t.Errorf("record of a failed upload = %+v, want a failure of prefix/file-1", got[1])
+	}
+}
+
+func TestClient_DeleteGeneration_Audit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 404, "message": "Not Found"}}`, http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	log := audit.New(filepath.Join(t.TempDir(), "audit.jsonl"), 0)
+	c, err := NewClientWithEndpoint(context.Background(), "test-bucket", "prefix/", "", srv.URL+"/storage/v1/", WithAuditLog(log))
+	if err != nil {
+		t.Fatalf("NewClientWithEndpoint() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	if err := c.DeleteGeneration(context.Background(), "file-1", 5); !IsNotFound(err) {
+		t.Fatalf("DeleteGeneration() error = %v, want not found", err)
+	}
+
+	got, err := log.Read(audit.Filter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Op != audit.OpDelete || got[0].Object != "prefix/file-1" || got[0].Generation != 5 || got[0].Outcome != audit.OutcomeNotFound {
+		t.Errorf("Read() = %+v, want a not found delete of prefix/file-1", got)
+	}
+}
+
+func TestSignedURLExpiry(t *testing.T) {
+	tests := []struct {
+		url  string
+		want time.Time
+	}{
+		{
+			url:  "https://storage.googleapis.com/bucket/file?X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Date=20240305T093000Z&X-Goog-Expires=900&X-Goog-Signature=abc",
+			want: time.Date(2024, 3, 5, 9, 45, 0, 0, time.UTC),
+		},
+		{url: "http://localhost:4443/bucket/file"},
+		{url: "https://storage.googleapis.com/bucket/file?X-Goog-Date=20240305T093000Z"},
+	}
+
+	for _, tt := range tests {
+		if got := signedURLExpiry(tt.url); !got.Equal(tt.want) {
+			t.Errorf("signedURLExpiry(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// BenchmarkUploadDelete compares the latency of uploading and deleting an
+// image over the HTTP and gRPC transports against fake-gcs-server:
+//
+//	go test -run '^$' -bench UploadDelete ./internal/gcs
+func BenchmarkUploadDelete(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping emulator benchmark in short mode")
+	}
+	if err := waitForEmulator(5 * time.Second); err != nil {
+		b.Skipf("emulator not available: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := createBucket(ctx, testBucket); err != nil {
+		b.Fatalf("failed to create bucket: %v", err)
+	}
+
+	data := bytes.Repeat([]byte{0xff}, 256<<10) // a typical slide image
+	for _, transport := range []string{TransportHTTP, TransportGRPC} {
+		b.Run(transport, func(b *testing.B) {
+			client, err := NewClientWithEndpoint(ctx, testBucket, "bench-prefix/", "", testEndpoint, WithTransport(transport))
+			if err != nil {
+				b.Fatalf("failed to create client: %v", err)
+			}
+			defer client.Close()
+
+			// The emulator may not serve gRPC.
+			if err := client.CheckBucket(ctx); err != nil {
+				b.Skipf("%s transport not available: %v", transport, err)
+			}
+
+			var upload, del time.Duration
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				filename := fmt.Sprintf("bench-%s-%d", transport, i)
+
+				start := time.Now()
+				if _, err := client.Upload(ctx, filename, bytes.NewReader(data), "image/png"); err != nil {
+					b.Fatalf("Upload() error = %v", err)
+				}
+				upload += time.Since(start)
+
+				start = time.Now()
+				if err := client.Delete(ctx, filename); err != nil {
+					b.Fatalf("Delete() error = %v", err)
+				}
+				del += time.Since(start)
+			}
+			b.ReportMetric(float64(upload.Microseconds())/float64(b.N), "upload-µs/op")
+			b.ReportMetric(float64(del.Microseconds())/float64(b.N), "delete-µs/op")
+		})
+	}
+}
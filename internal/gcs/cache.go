@@ -0,0 +1,128 @@
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// defaultCacheTTL is how long a cached upload is reused if the bucket
+	// lifecycle cannot be read.
+	defaultCacheTTL = 24 * time.Hour
+	// cacheExpiryMargin keeps a cached upload from being reused shortly
+	// before the bucket lifecycle deletes it, while its URL is still fetched.
+	cacheExpiryMargin = time.Hour
+)
+
+// cacheEntry is an uploaded object recorded in the upload cache.
+type cacheEntry struct {
+	ID       string    `json:"id"`
+	Uploaded time.Time `json:"uploaded"`
+}
+
+// cachedUpload returns the result of reusing a cached upload of the same
+// content, if the object still exists, is not about to expire and is not
+// scheduled for deletion.
+func (c *Client) cachedUpload(ctx context.Context, key string, size int64, sums Checksums) (*UploadResult, bool) {
+	path := filepath.Join(c.cacheDir, key+".json")
+	entry, ok := loadCacheEntry(path)
+	if !ok {
+		return nil, false
+	}
+
+	objectName := c.objectName(entry.ID)
+	attrs, err := c.object(objectName).Attrs(ctx)
+	if err != nil {
+		c.logger.DebugContext(ctx, "cached upload not reused", "object", objectName, "error", err)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			os.Remove(path)
+		}
+		return nil, false
+	}
+	if attrs.Size != size || attrs.CRC32C != sums.CRC32C || !attrs.CustomTime.IsZero() {
+		c.logger.DebugContext(ctx, "cached upload not reused", "object", objectName, "reason", "changed or scheduled for deletion")
+		os.Remove(path)
+		return nil, false
+	}
+	if ttl := c.cacheTTL(ctx); ttl > 0 && time.Since(attrs.Created) > ttl {
+		c.logger.DebugContext(ctx, "cached upload not reused", "object", objectName, "reason", "expiring", "created", attrs.Created)
+		os.Remove(path)
+		return nil, false
+	}
+
+	url, err := c.signURL(ctx, entry.ID)
+	if err != nil {
+		return nil, false
+	}
+	c.logger.DebugContext(ctx, "reusing cached upload", "object", objectName, "generation", attrs.Generation)
+	return &UploadResult{ID: entry.ID, URL: url, Generation: attrs.Generation, Checksums: sums, Cached: true}, true
+}
+
+// cacheTTL returns how long after its creation an object may be reused, or
+// zero if objects do not expire. Objects are deleted by the bucket lifecycle
+// once they reach the age of its delete rules, so they are only reused until
+// shortly before that. The lifecycle is read once per client.
+func (c *Client) cacheTTL(ctx context.Context) time.Duration {
+	c.cacheTTLOnce.Do(func() {
+		attrs, err := c.bucketHandle().Attrs(ctx)
+		if err != nil {
+			c.cacheTTLValue = defaultCacheTTL
+			return
+		}
+		c.cacheTTLValue = lifecycleTTL(attrs.Lifecycle)
+	})
+	return c.cacheTTLValue
+}
+
+// lifecycleTTL returns the reuse period of objects under a bucket lifecycle:
+// the age of the earliest delete rule less a margin, or zero if no rule
+// deletes objects by age.
+func lifecycleTTL(lc storage.Lifecycle) time.Duration {
+	var ttl time.Duration
+	for _, rule := range lc.Rules {
+		if rule.Action.Type != storage.DeleteAction || rule.Condition.AgeInDays <= 0 {
+			continue
+		}
+		age := time.Duration(rule.Condition.AgeInDays)*24*time.Hour - cacheExpiryMargin
+		if ttl == 0 || age < ttl {
+			ttl = age
+		}
+	}
+	return ttl
+}
+
+// loadCacheEntry reads a cache entry, ignoring missing or corrupt ones.
+func loadCacheEntry(path string) (*cacheEntry, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.ID == "" {
+		os.Remove(path)
+		return nil, false
+	}
+	return &e, true
+}
+
+// saveCacheEntry records an uploaded object in the upload cache.
+func saveCacheEntry(path string, e *cacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to save upload cache entry: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save upload cache entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// newCacheTestClient returns a client with an upload cache in dir, backed by
+// a JSON API serving one object created at created, with a custom time unless
+// customTime is zero, in a bucket whose lifecycle deletes objects after a day.
+func newCacheTestClient(t *testing.T, dir string, content []byte, created, customTime time.Time) *Client {
+	t.Helper()
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.Checksum(content, crc32cTable))
+	attrs := map[string]string{
+		"bucket":      "test-bucket",
+		"name":        "prefix/file-1",
+		"size":        strconv.Itoa(len(content)),
+		"crc32c":      base64.StdEncoding.EncodeToString(crc[:]),
+		"generation":  "7",
+		"timeCreated": created.Format(time.RFC3339),
+	}
+	if !customTime.IsZero() {
+		attrs["customTime"] = customTime.Format(time.RFC3339)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/storage/v1/b/test-bucket/o/prefix/file-1":
+			json.NewEncoder(w).Encode(attrs)
+		case "/storage/v1/b/test-bucket":
+			w.Write([]byte(`{"name": "test-bucket", "lifecycle": {"rule": [{"action": {"type": "Delete"}, "condition": {"age": 1}}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClientWithEndpoint(context.Background(), "test-bucket", "prefix/", "", srv.URL+"/storage/v1/", WithUploadCache(dir))
+	if err != nil {
+		t.Fatalf("NewClientWithEndpoint() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// writeCacheEntry records id as the upload of content in the cache of c.
+func writeCacheEntry(t *testing.T, c *Client, content []byte, id string) string {
+	t.Helper()
+	path := filepath.Join(c.cacheDir, c.sessionKey(content, "image/png", c.metadata)+".json")
+	if err := saveCacheEntry(path, &cacheEntry{ID: id, Uploaded: time.Now()}); err != nil {
+		t.Fatalf("saveCacheEntry() error = %v", err)
+	}
+	return path
+}
+
+func TestClient_Upload_Cached(t *testing.T) {
+	content := []byte("image")
+	c := newCacheTestClient(t, t.TempDir(), content, time.Now().Add(-time.Hour), time.Time{})
+	writeCacheEntry(t, c, content, "file-1")
+
+	// The fake server rejects uploads, so only a cache hit succeeds.
+	res, err := c.Upload(context.Background(), "file-2", bytes.NewReader(content), "image/png")
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if res.ID != "file-1" || res.Generation != 7 || !res.Cached {
+		t.Errorf("Upload() = %+v, want cached file-1 with generation 7", res)
+	}
+}
+
+func TestClient_Upload_CacheKeyedByMetadata(t *testing.T) {
+	f := newFakeServer(t)
+	c := newTestClient(t, f, WithUploadCache(t.TempDir()), WithMetadata(map[string]string{MetadataSession: "s1"}))
+	content := []byte("image")
+	ctx := context.Background()
+
+	if _, err := c.Upload(ctx, "s1/file-1", bytes.NewReader(content), "image/png"); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	res, err := c.Upload(ctx, "s1/file-2", bytes.NewReader(content), "image/png")
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if !res.Cached || res.ID != "s1/file-1" {
+		t.Errorf("Upload() in the same session = %+v, want cached s1/file-1", res)
+	}
+
+	// Another session must not get the object of the first one, which the
+	// first session deletes when it ends.
+	res, err = c.Upload(ctx, "s2/file-1", bytes.NewReader(content), "image/png", WithUploadMetadata(map[string]string{MetadataSession: "s2"}))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if res.Cached || res.ID != "s2/file-1" {
+		t.Errorf("Upload() in another session = %+v, want a new upload of s2/file-1", res)
+	}
+}
+
+func TestClient_cachedUpload_Misses(t *testing.T) {
+	content := []byte("image")
+	tests := []struct {
+		name       string
+		id         string
+		content    []byte
+		created    time.Time
+		customTime time.Time
+	}{
+		{name: "object deleted", id: "file-2", content: content, created: time.Now()},
+		{name: "content differs", id: "file-1", content: []byte("other"), created: time.Now()},
+		{name: "expiring by lifecycle", id: "file-1", content: content, created: time.Now().Add(-23*time.Hour - time.Minute)},
+		{name: "deletion scheduled", id: "file-1", content: content, created: time.Now(), customTime: time.Now().Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCacheTestClient(t, t.TempDir(), tt.content, tt.created, tt.customTime)
+			path := writeCacheEntry(t, c, content, tt.id)
+
+			if res, ok := c.cachedUpload(context.Background(), c.sessionKey(content, "image/png", c.metadata), int64(len(content)), checksumsOf(content)); ok {
+				t.Errorf("cachedUpload() = %+v, want a miss", res)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Error("cachedUpload() should remove the stale entry")
+			}
+		})
+	}
+}
+
+func TestLifecycleTTL(t *testing.T) {
+	deleteAfter := func(days int64) storage.LifecycleRule {
+		return storage.LifecycleRule{
+			Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+			Condition: storage.LifecycleCondition{AgeInDays: days},
+		}
+	}
+	tests := []struct {
+		name  string
+		rules []storage.LifecycleRule
+		want  time.Duration
+	}{
+		{name: "no rules", want: 0},
+		{name: "delete after a day", rules: []storage.LifecycleRule{deleteAfter(1)}, want: 23 * time.Hour},
+		{name: "earliest delete rule", rules: []storage.LifecycleRule{deleteAfter(30), deleteAfter(7)}, want: 7*24*time.Hour - time.Hour},
+		{
+			name: "storage class change",
+			rules: []storage.LifecycleRule{{
+				Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: "NEARLINE"},
+				Condition: storage.LifecycleCondition{AgeInDays: 1},
+			}},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lifecycleTTL(storage.Lifecycle{Rules: tt.rules}); got != tt.want {
+				t.Errorf("lifecycleTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package gcs
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksums holds the CRC32C and MD5 checksums of an object's content.
+type Checksums struct {
+	CRC32C uint32
+	MD5    []byte
+}
+
+// String formats the checksums base64 encoded, as reported by GCS and
+// "gcloud storage hash".
+func (s Checksums) String() string {
+	return fmt.Sprintf("crc32c=%s md5=%s", s.CRC32CBase64(), s.MD5Base64())
+}
+
+// CRC32CBase64 returns the base64 encoded big-endian CRC32C checksum.
+func (s Checksums) CRC32CBase64() string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], s.CRC32C)
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// MD5Base64 returns the base64 encoded MD5 checksum.
+func (s Checksums) MD5Base64() string {
+	return base64.StdEncoding.EncodeToString(s.MD5)
+}
+
+// checksumReader computes the checksums of the content read through it, so
+// that uploads are verified without buffering the content.
+type checksumReader struct {
+	r      io.Reader
+	crc    hash.Hash32
+	md5    hash.Hash
+	sha256 hash.Hash // recorded in the audit log
+	n      int64     // bytes read
+	eof    bool      // whether all of the content has been read
+}
+
+// newChecksumReader returns a reader of r that computes its checksums.
+func newChecksumReader(r io.Reader) *checksumReader {
+	cr := &checksumReader{crc: crc32.New(crc32cTable), md5: md5.New(), sha256: sha256.New()}
+	cr.r = io.TeeReader(r, io.MultiWriter(cr.crc, cr.md5, cr.sha256))
+	return cr
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// Checksums returns the checksums of the content read so far.
+func (r *checksumReader) Checksums() Checksums {
+	return Checksums{CRC32C: r.crc.Sum32(), MD5: r.md5.Sum(nil)}
+}
+
+// SHA256 returns the hex encoded SHA-256 of the content read so far.
+func (r *checksumReader) SHA256() string {
+	return hex.EncodeToString(r.sha256.Sum(nil))
+}
+
+// contentLength returns the size of the content of r if it is known without
+// reading it, as for files and in-memory readers, or -1 otherwise.
+func contentLength(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Stat() (fs.FileInfo, error) }:
+		if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size()
+		}
+	}
+	return -1
+}
+
+// verifyChecksums compares the checksums of the uploaded content with the
+// ones GCS computed for the stored object. GCS omits the MD5 of some objects
+// (e.g., composite objects), so it is only compared when present.
+func verifyChecksums(want Checksums, crc32c uint32, md5sum []byte) error {
+	if crc32c != want.CRC32C {
+		got := Checksums{CRC32C: crc32c}
+		return fmt.Errorf("checksum mismatch: crc32c is %s, want %s", got.CRC32CBase64(), want.CRC32CBase64())
+	}
+	if len(md5sum) > 0 && !bytes.Equal(md5sum, want.MD5) {
+		got := Checksums{MD5: md5sum}
+		return fmt.Errorf("checksum mismatch: md5 is %s, want %s", got.MD5Base64(), want.MD5Base64())
+	}
+	return nil
+}
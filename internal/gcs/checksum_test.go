@@ -0,0 +1,168 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// checksumsOf returns the checksums of content.
+func checksumsOf(content []byte) Checksums {
+	cr := newChecksumReader(bytes.NewReader(content))
+	io.Copy(io.Discard, cr)
+	return cr.Checksums()
+}
+
+func TestChecksumReader(t *testing.T) {
+	cr := newChecksumReader(iotest.OneByteReader(strings.NewReader("hello world")))
+	data, err := io.ReadAll(cr)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(data) != "hello world" || cr.n != int64(len(data)) {
+		t.Errorf("read %q (%d bytes), want %q", data, cr.n, "hello world")
+	}
+
+	want := "crc32c=yZRlqg== md5=XrY7u+Ae7tCTyyK7j1rNww=="
+	if got := cr.Checksums().String(); got != want {
+		t.Errorf("Checksums().String() = %q, want %q", got, want)
+	}
+	if got, want := cr.SHA256(), "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"; got != want {
+		t.Errorf("SHA256() = %q, want %q", got, want)
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.png")
+	if err := os.WriteFile(path, []byte("image"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		name string
+		r    io.Reader
+		want int64
+	}{
+		{name: "bytes", r: bytes.NewReader([]byte("image")), want: 5},
+		{name: "file", r: f, want: 5},
+		{name: "stream", r: iotest.OneByteReader(strings.NewReader("image")), want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contentLength(tt.r); got != tt.want {
+				t.Errorf("contentLength() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	want := checksumsOf([]byte("hello world"))
+
+	tests := []struct {
+		name    string
+		crc32c  uint32
+		md5     []byte
+		wantErr bool
+	}{
+		{
+			name:   "match",
+			crc32c: want.CRC32C,
+			md5:    want.MD5,
+		},
+		{
+			name:   "no md5",
+			crc32c: want.CRC32C,
+		},
+		{
+			name:    "crc32c mismatch",
+			crc32c:  want.CRC32C + 1,
+			md5:     want.MD5,
+			wantErr: true,
+		},
+		{
+			name:    "md5 mismatch",
+			crc32c:  want.CRC32C,
+			md5:     bytes.Repeat([]byte{0}, 16),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChecksums(want, tt.crc32c, tt.md5)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyChecksums() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_Upload_SendsChecksums(t *testing.T) {
+	f := newFakeServer(t)
+	c := newTestClient(t, f)
+	content := []byte("image")
+
+	// A reader without a known length is read first, since it is small.
+	if _, err := c.Upload(context.Background(), "file-1", iotest.OneByteReader(bytes.NewReader(content)), "image/png"); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	obj := f.object("prefix/file-1")
+	if obj == nil || !bytes.Equal(obj.data, content) {
+		t.Fatalf("stored object = %+v, want the uploaded content", obj)
+	}
+	if got, want := obj.sent.String(), checksumsOf(content).String(); got != want {
+		t.Errorf("sent checksums = %s, want %s", got, want)
+	}
+
+	f.corrupt = true
+	if _, err := c.Upload(context.Background(), "file-2", bytes.NewReader(content), "image/png"); err == nil {
+		t.Fatal("Upload() of a corrupted write should fail")
+	}
+	if obj := f.object("prefix/file-2"); obj != nil {
+		t.Error("GCS stored a corrupted write")
+	}
+}
+
+func TestClient_Upload_VerifiesStreamedChecksums(t *testing.T) {
+	f := newFakeServer(t)
+	c := newTestClient(t, f)
+	content := []byte("image")
+
+	// Content larger than maxBufferedUpload is streamed without checksums.
+	defer func(n int64) { maxBufferedUpload = n }(maxBufferedUpload)
+	maxBufferedUpload = 2
+
+	res, err := c.Upload(context.Background(), "file-1", iotest.OneByteReader(bytes.NewReader(content)), "image/png")
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if got, want := res.Checksums.String(), checksumsOf(content).String(); got != want {
+		t.Errorf("Upload() checksums = %s, want %s", got, want)
+	}
+	obj := f.object("prefix/file-1")
+	if obj == nil || !bytes.Equal(obj.data, content) {
+		t.Fatalf("stored object = %+v, want the uploaded content", obj)
+	}
+	if obj.sent.MD5 != nil {
+		t.Errorf("sent checksums = %s, want none for a streamed upload", obj.sent)
+	}
+
+	f.corrupt = true
+	if _, err := c.Upload(context.Background(), "file-2", bytes.NewReader(content), "image/png"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Upload() of a corrupted write error = %v, want a checksum mismatch", err)
+	}
+	if obj := f.object("prefix/file-2"); obj != nil {
+		t.Error("Upload() left the corrupted object behind")
+	}
+}
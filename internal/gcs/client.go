@@ -1,13 +1,31 @@
 package gcs
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/minodisk/reprint/internal/audit"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -17,40 +35,270 @@ const (
 
 // Client wraps the GCS client.
 type Client struct {
-	client   *storage.Client
-	bucket   string
-	prefix   string
-	endpoint string // custom endpoint for emulator
+	client          *storage.Client
+	bucket          string
+	prefix          string
+	endpoint        string            // custom endpoint for emulator
+	credentialsJSON []byte            // service account key JSON, used instead of the credentials file
+	metadata        map[string]string // custom metadata set on uploaded objects
+
+	// Headers and storage class of uploaded objects; empty means the GCS default.
+	cacheControl       string
+	contentDisposition string
+	storageClass       string
+
+	kmsKey        string // Cloud KMS key name for CMEK
+	encryptionKey []byte // AES-256 key for CSEK
+
+	chunkSize          int                     // upload chunk size; -1 means the library default
+	chunkRetryDeadline time.Duration           // per-chunk retry deadline; zero means the library default
+	sessionDir         string                  // directory for resumable upload sessions; empty disables them
+	progress           func(sent, total int64) // reports progress of multi-chunk uploads
+
+	cacheDir      string        // directory of the upload cache; empty disables it
+	cacheTTLOnce  sync.Once     // reads the bucket lifecycle for cacheTTLValue
+	cacheTTLValue time.Duration // how long after creation cached objects are reused
+
+	billingProject string // project billed for requests to requester-pays buckets
+
+	logger   *slog.Logger // logs requests, retries and their outcomes
+	auditLog *audit.Log   // records uploads and deletes; nil disables it
+	actor    string       // service account recorded in the audit log
+
+	tracerProvider trace.TracerProvider // creates spans of operations
+	meterProvider  metric.MeterProvider // creates metrics of operations
+	tracer         trace.Tracer
+	metrics        instruments
+
+	transport  string                // TransportHTTP or TransportGRPC
+	signingKey *signingKey           // key to sign URLs with, if the client does not keep it
+	clientOpts []option.ClientOption // options for HTTP clients of the JSON API
+}
+
+// Option is a function that modifies Client.
+type Option func(*Client)
+
+// WithCredentialsJSON authenticates with service account key JSON held in
+// memory instead of a key file.
+func WithCredentialsJSON(data []byte) Option {
+	return func(c *Client) {
+		c.credentialsJSON = data
+	}
+}
+
+// WithMetadata sets custom metadata on every uploaded object.
+func WithMetadata(metadata map[string]string) Option {
+	return func(c *Client) {
+		c.metadata = metadata
+	}
+}
+
+// WithCacheControl sets the Cache-Control header of uploaded objects.
+func WithCacheControl(cacheControl string) Option {
+	return func(c *Client) {
+		c.cacheControl = cacheControl
+	}
+}
+
+// WithContentDisposition sets the Content-Disposition header of uploaded objects.
+func WithContentDisposition(contentDisposition string) Option {
+	return func(c *Client) {
+		c.contentDisposition = contentDisposition
+	}
+}
+
+// WithStorageClass sets the storage class of uploaded objects
+// (e.g., "STANDARD", "NEARLINE"). Empty means the bucket default.
+func WithStorageClass(storageClass string) Option {
+	return func(c *Client) {
+		c.storageClass = strings.ToUpper(storageClass)
+	}
+}
+
+// WithKMSKey encrypts uploaded objects with a customer-managed Cloud KMS key
+// (projects/P/locations/L/keyRings/R/cryptoKeys/K).
+func WithKMSKey(keyName string) Option {
+	return func(c *Client) {
+		c.kmsKey = keyName
+	}
+}
+
+// WithEncryptionKey encrypts uploaded objects with a customer-supplied AES-256
+// key (CSEK). Google does not store the key, so it is required to read the
+// objects, and signed URLs carry the x-goog-encryption-* headers that the
+// fetching side must send.
+func WithEncryptionKey(key []byte) Option {
+	return func(c *Client) {
+		c.encryptionKey = key
+	}
+}
+
+// WithChunkSize sets the size of upload chunks. Zero uploads each object in
+// a single request, without retries of partial uploads.
+func WithChunkSize(size int) Option {
+	return func(c *Client) {
+		c.chunkSize = size
+	}
+}
+
+// WithChunkRetryDeadline sets how long a failing upload chunk is retried.
+func WithChunkRetryDeadline(d time.Duration) Option {
+	return func(c *Client) {
+		c.chunkRetryDeadline = d
+	}
+}
+
+// WithResumableSessions persists resumable upload sessions in dir, so that
+// retrying the upload of the same content resumes the interrupted upload
+// instead of starting over.
+func WithResumableSessions(dir string) Option {
+	return func(c *Client) {
+		c.sessionDir = dir
+	}
+}
+
+// WithUploadCache records uploaded objects in dir by their content, so that
+// uploading the same content again reuses the object while it exists instead
+// of uploading it again.
+func WithUploadCache(dir string) Option {
+	return func(c *Client) {
+		c.cacheDir = dir
+	}
+}
+
+// WithProgress calls fn with the number of bytes sent after each chunk of
+// uploads that take more than one chunk. The total is -1 if the size of the
+// upload is not known in advance, as for data read from a pipe.
+func WithProgress(fn func(sent, total int64)) Option {
+	return func(c *Client) {
+		c.progress = fn
+	}
+}
+
+// WithBillingProject bills requests to project, as required for
+// requester-pays buckets. Signed URLs carry it as the userProject parameter.
+func WithBillingProject(project string) Option {
+	return func(c *Client) {
+		c.billingProject = project
+	}
+}
+
+// WithTransport selects the transport of the client, TransportHTTP (default)
+// or TransportGRPC. Resumable upload sessions always use the JSON API.
+func WithTransport(transport string) Option {
+	return func(c *Client) {
+		c.transport = strings.ToLower(transport)
+	}
+}
+
+// WithLogger logs requests, their retries and outcomes to logger: completed
+// operations and retries at info, details like cache lookups at debug.
+// Without it nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithAuditLog records every upload, delete, and scheduled deletion in log,
+// whether it succeeded or not, along with the service account making it.
+func WithAuditLog(log *audit.Log) Option {
+	return func(c *Client) {
+		c.auditLog = log
+	}
+}
+
+// WithTelemetry records spans and metrics of uploads, signing, and deletes
+// with the given providers instead of the global ones.
+func WithTelemetry(tp trace.TracerProvider, mp metric.MeterProvider) Option {
+	return func(c *Client) {
+		c.tracerProvider = tp
+		c.meterProvider = mp
+	}
 }
 
 // NewClient creates a new GCS client.
-// credentials must be a path to a service account key file (required for signed URLs).
-func NewClient(ctx context.Context, bucket, prefix, credentials string) (*Client, error) {
-	return NewClientWithEndpoint(ctx, bucket, prefix, credentials, "")
+// credentials must be a path to a service account key file (required for signed URLs),
+// unless the key is given with WithCredentialsJSON.
+func NewClient(ctx context.Context, bucket, prefix, credentials string, opts ...Option) (*Client, error) {
+	return NewClientWithEndpoint(ctx, bucket, prefix, credentials, "", opts...)
 }
 
 // NewClientWithEndpoint creates a new GCS client with a custom endpoint.
 // This is useful for testing with emulators like fake-gcs-server.
-func NewClientWithEndpoint(ctx context.Context, bucket, prefix, credentials, endpoint string) (*Client, error) {
-	var opts []option.ClientOption
-	if credentials != "" {
-		opts = append(opts, option.WithCredentialsFile(credentials))
+func NewClientWithEndpoint(ctx context.Context, bucket, prefix, credentials, endpoint string, opts ...Option) (*Client, error) {
+	c := &Client{
+		bucket:    bucket,
+		prefix:    prefix,
+		endpoint:  endpoint,
+		chunkSize: -1,
+		logger:    slog.New(slog.DiscardHandler),
+
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.tracer = c.tracerProvider.Tracer(instrumentationName)
+	c.metrics = newInstruments(c.meterProvider)
+	if err := ValidateStorageClass(c.storageClass); err != nil {
+		return nil, err
+	}
+	if err := ValidateKMSKeyName(c.kmsKey); err != nil {
+		return nil, err
+	}
+	if c.encryptionKey != nil && len(c.encryptionKey) != 32 {
+		return nil, fmt.Errorf("invalid encryption key: must be 32 bytes (AES-256), got %d", len(c.encryptionKey))
+	}
+	if c.encryptionKey != nil && c.kmsKey != "" {
+		return nil, fmt.Errorf("a KMS key and a customer-supplied encryption key cannot be used together")
+	}
+	if err := ValidateTransport(c.transport); err != nil {
+		return nil, err
+	}
+	if c.chunkSize < -1 {
+		return nil, fmt.Errorf("invalid chunk size %d: must not be negative", c.chunkSize)
+	}
+
+	var clientOpts []option.ClientOption
+	if len(c.credentialsJSON) > 0 {
+		clientOpts = append(clientOpts, option.WithCredentialsJSON(c.credentialsJSON))
+	} else if credentials != "" {
+		clientOpts = append(clientOpts, option.WithCredentialsFile(credentials))
 	}
 	if endpoint != "" {
-		opts = append(opts, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+		clientOpts = append(clientOpts, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+	}
+
+	c.clientOpts = clientOpts
+	if c.auditLog != nil {
+		c.actor = serviceAccountEmail(c.credentialsJSON, credentials)
 	}
 
-	client, err := storage.NewClient(ctx, opts...)
+	var client *storage.Client
+	var err error
+	if c.transport == TransportGRPC {
+		grpcOpts := clientOpts
+		if endpoint != "" {
+			if grpcOpts, err = grpcEmulatorOptions(endpoint); err != nil {
+				return nil, err
+			}
+		}
+		if c.signingKey, err = loadSigningKey(c.credentialsJSON, credentials); err != nil {
+			return nil, err
+		}
+		client, err = storage.NewGRPCClient(ctx, grpcOpts...)
+	} else {
+		client, err = storage.NewClient(ctx, clientOpts...)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GCS client: %w", err)
 	}
+	client.SetRetry(storage.WithErrorFunc(c.shouldRetry))
+	c.client = client
 
-	return &Client{
-		client:   client,
-		bucket:   bucket,
-		prefix:   prefix,
-		endpoint: endpoint,
-	}, nil
+	return c, nil
 }
 
 // Close closes the GCS client.
@@ -58,23 +306,215 @@ func (c *Client) Close() error {
 	return c.client.Close()
 }
 
+// UploadResult describes an uploaded object.
+type UploadResult struct {
+	// ID is the object id, relative to the client prefix. It differs from the
+	// requested one when an interrupted upload of the same content was resumed
+	// or a cached upload was reused.
+	ID string
+	// URL is a signed URL to read the object.
+	URL string
+	// Generation identifies the uploaded version of the object.
+	Generation int64
+	// Checksums are the verified checksums of the object's content.
+	Checksums Checksums
+	// Cached reports that an earlier upload of the same content was reused.
+	Cached bool
+}
+
+var (
+	// ErrObjectExists is returned by Upload when an object with the same
+	// name already exists.
+	ErrObjectExists = errors.New("object already exists")
+	// ErrGenerationMismatch is returned by DeleteGeneration when the live
+	// object has a different generation, e.g., a newer object reused the name.
+	ErrGenerationMismatch = errors.New("object generation does not match")
+)
+
+// UploadOption configures a single upload.
+type UploadOption func(*uploadOptions)
+
+type uploadOptions struct {
+	metadata map[string]string
+}
+
+// WithUploadMetadata sets the custom metadata of an upload instead of the
+// metadata set with WithMetadata, e.g., for uploads on behalf of different
+// deck runs.
+func WithUploadMetadata(metadata map[string]string) UploadOption {
+	return func(o *uploadOptions) {
+		o.metadata = metadata
+	}
+}
+
 // Upload uploads data to GCS and returns a signed URL.
-func (c *Client) Upload(ctx context.Context, filename string, data io.Reader, contentType string) (string, error) {
+// It never overwrites an existing object and fails with ErrObjectExists instead.
+// The CRC32C and MD5 checksums of data are sent with it, so that GCS rejects
+// a corrupted write, unless data is too large to read before uploading. They
+// are also compared with the ones GCS computed for the stored object, which
+// is deleted again if they differ.
+func (c *Client) Upload(ctx context.Context, filename string, data io.Reader, contentType string, opts ...UploadOption) (*UploadResult, error) {
+	objectName := c.objectName(filename)
+	if err := ValidateObjectName(objectName); err != nil {
+		return nil, err
+	}
+	o := uploadOptions{metadata: c.metadata}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ctx, span := c.startSpan(ctx, opUpload, objectName)
+	span.SetAttributes(attribute.String("gcs.object.content_type", contentType))
+	start := time.Now()
+	cr := newChecksumReader(data)
+	res, err := c.uploadCached(ctx, filename, cr, contentLength(data), contentType, o.metadata)
+	attrs := []any{"bucket", c.bucket, "object", objectName, "bytes", cr.n, "latency", time.Since(start)}
+	span.SetAttributes(attribute.Int64("gcs.object.size", cr.n))
+	if err == nil {
+		span.SetAttributes(attribute.String("gcs.object.id", res.ID), attribute.Int64("gcs.object.generation", res.Generation), attribute.Bool("reprint.cached", res.Cached))
+		if !res.Cached {
+			c.metrics.uploaded.Add(ctx, cr.n, metric.WithAttributes(attribute.String("gcs.bucket", c.bucket)))
+		}
+	}
+	c.endSpan(ctx, span, opUpload, start, err)
+
+	r := audit.Record{Op: audit.OpUpload, Object: objectName, Size: cr.n, SHA256: cr.SHA256(), MIME: contentType}
+	if err != nil {
+		c.logger.InfoContext(ctx, "upload failed", append(attrs, "error", err)...)
+		c.record(ctx, r, err)
+		return nil, err
+	}
+	c.logger.InfoContext(ctx, "uploaded object", append(attrs, "id", res.ID, "generation", res.Generation, "cached", res.Cached)...)
+
+	r.Object, r.Generation, r.URLExpires = c.objectName(res.ID), res.Generation, signedURLExpiry(res.URL)
+	if res.Cached {
+		r.Outcome = audit.OutcomeCached
+	}
+	c.record(ctx, r, nil)
+	return res, nil
+}
+
+// maxBufferedUpload is the size up to which content is read before it is
+// uploaded, so that its checksums are sent with it. The writer buffers a
+// chunk of this size anyway.
+var maxBufferedUpload int64 = 16 << 20
+
+// uploadCached uploads data of size bytes (-1 if unknown) with metadata, or
+// reuses a cached upload of the same content. The upload cache and resumable sessions are
+// keyed by the content, so they read all of it before uploading. Otherwise
+// content larger than maxBufferedUpload is streamed.
+func (c *Client) uploadCached(ctx context.Context, filename string, data *checksumReader, size int64, contentType string, metadata map[string]string) (*UploadResult, error) {
+	var content []byte
+	var err error
+	if c.cacheDir == "" && c.sessionDir == "" {
+		content, err = io.ReadAll(io.LimitReader(data, maxBufferedUpload+1))
+		if err == nil && !data.eof {
+			return c.upload(ctx, filename, io.MultiReader(bytes.NewReader(content), data), data, size, contentType, metadata)
+		}
+	} else {
+		content, err = io.ReadAll(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload data: %w", err)
+	}
+	sums := data.Checksums()
+
+	var key string
+	if c.cacheDir != "" {
+		key = c.sessionKey(content, contentType, metadata)
+		if res, ok := c.cachedUpload(ctx, key, int64(len(content)), sums); ok {
+			return res, nil
+		}
+	}
+
+	var res *UploadResult
+	if c.sessionDir != "" {
+		res, err = c.uploadResumable(ctx, filename, content, sums, contentType, metadata)
+	} else {
+		res, err = c.upload(ctx, filename, bytes.NewReader(content), data, int64(len(content)), contentType, metadata)
+	}
+	if err != nil || key == "" {
+		return res, err
+	}
+	// The cache only saves work, so failing to record an upload is not an error.
+	if err := saveCacheEntry(filepath.Join(c.cacheDir, key+".json"), &cacheEntry{ID: res.ID, Uploaded: time.Now()}); err != nil {
+		c.logger.DebugContext(ctx, "failed to record upload in the cache", "error", err)
+	}
+	return res, nil
+}
+
+// upload streams the content of r, size bytes (-1 if unknown), to a new
+// object with metadata. The checksums of data cover the content once r is
+// read. If data was read to the end already, they are sent with the content
+// so that GCS rejects a corrupted write; otherwise the stored object is
+// verified against them afterwards.
+func (c *Client) upload(ctx context.Context, filename string, r io.Reader, data *checksumReader, size int64, contentType string, metadata map[string]string) (*UploadResult, error) {
 	objectName := c.objectName(filename)
-	obj := c.client.Bucket(c.bucket).Object(objectName)
+	obj := c.object(objectName)
 
-	w := obj.NewWriter(ctx)
+	// Canceling the context aborts the upload, so that a failed read does
+	// not leave a truncated object behind.
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := obj.If(storage.Conditions{DoesNotExist: true}).NewWriter(wctx)
 	w.ContentType = contentType
+	w.Metadata = metadata
+	w.CacheControl = c.cacheControl
+	w.ContentDisposition = c.contentDisposition
+	w.StorageClass = c.storageClass
+	w.KMSKeyName = c.kmsKey
+	if c.chunkSize >= 0 {
+		w.ChunkSize = c.chunkSize
+	}
+	if c.chunkRetryDeadline > 0 {
+		w.ChunkRetryDeadline = c.chunkRetryDeadline
+	}
+	if c.progress != nil {
+		w.ProgressFunc = func(sent int64) { c.progress(sent, size) }
+	}
+	if data.eof {
+		sums := data.Checksums()
+		w.CRC32C, w.SendCRC32C, w.MD5 = sums.CRC32C, true, sums.MD5
+	}
 
-	if _, err := io.Copy(w, data); err != nil {
-		return "", fmt.Errorf("failed to write to GCS: %w", err)
+	if _, err := io.Copy(w, r); err != nil {
+		cancel()
+		w.Close()
+		return nil, fmt.Errorf("failed to write to GCS: %w", err)
 	}
 
 	if err := w.Close(); err != nil {
-		return "", fmt.Errorf("failed to close GCS writer: %w", err)
+		if isPreconditionFailed(err) {
+			return nil, fmt.Errorf("failed to upload %q: %w", objectName, ErrObjectExists)
+		}
+		return nil, fmt.Errorf("failed to close GCS writer: %w", err)
+	}
+
+	attrs := w.Attrs()
+	sums := data.Checksums()
+	if err := verifyChecksums(sums, attrs.CRC32C, attrs.MD5); err != nil {
+		// Do not leave a corrupted object behind.
+		if delErr := obj.If(storage.Conditions{GenerationMatch: attrs.Generation}).Delete(ctx); delErr != nil {
+			return nil, fmt.Errorf("%w (failed to delete the corrupted object: %v)", err, delErr)
+		}
+		return nil, err
+	}
+
+	url, err := c.signURL(ctx, filename)
+	if err != nil {
+		return nil, err
 	}
+	return &UploadResult{ID: filename, URL: url, Generation: attrs.Generation, Checksums: sums}, nil
+}
 
-	return c.SignedURL(filename, DefaultSignedURLExpiration)
+// signURL returns a signed URL for an uploaded object, traced as part of the
+// upload.
+func (c *Client) signURL(ctx context.Context, filename string) (string, error) {
+	_, span := c.startSpan(ctx, opSignURL, c.objectName(filename))
+	start := time.Now()
+	url, err := c.SignedURL(filename, DefaultSignedURLExpiration)
+	c.endSpan(ctx, span, opSignURL, start, err)
+	return url, err
 }
 
 // SignedURL returns a signed URL for an object with the specified expiration.
@@ -86,40 +526,98 @@ func (c *Client) SignedURL(filename string, expiration time.Duration) (string, e
 	}
 
 	objectName := c.objectName(filename)
+	// V4 signing is required for query parameters such as userProject.
 	opts := &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
 		Method:  "GET",
 		Expires: time.Now().Add(expiration),
+		Headers: c.encryptionHeaders(),
+	}
+	if c.billingProject != "" {
+		opts.QueryParameters = url.Values{"userProject": {c.billingProject}}
+	}
+	if c.signingKey != nil {
+		opts.GoogleAccessID = c.signingKey.email
+		opts.PrivateKey = c.signingKey.privateKey
 	}
 
-	url, err := c.client.Bucket(c.bucket).SignedURL(objectName, opts)
+	signedURL, err := c.bucketHandle().SignedURL(objectName, opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate signed URL: %w", err)
 	}
 
-	return url, nil
+	return signedURL, nil
 }
 
 // Delete deletes an object from GCS.
 func (c *Client) Delete(ctx context.Context, filename string) error {
+	return c.DeleteGeneration(ctx, filename, 0)
+}
+
+// DeleteGeneration deletes an object from GCS only if its generation matches,
+// and fails with ErrGenerationMismatch otherwise. A zero generation matches
+// any object.
+func (c *Client) DeleteGeneration(ctx context.Context, filename string, generation int64) error {
 	objectName := c.objectName(filename)
-	obj := c.client.Bucket(c.bucket).Object(objectName)
+	obj := c.bucketHandle().Object(objectName)
+	if generation != 0 {
+		obj = obj.If(storage.Conditions{GenerationMatch: generation})
+	}
 
-	if err := obj.Delete(ctx); err != nil {
+	ctx, span := c.startSpan(ctx, opDelete, objectName)
+	start := time.Now()
+	err := obj.Delete(ctx)
+	c.endSpan(ctx, span, opDelete, start, err)
+	attrs := []any{"bucket", c.bucket, "object", objectName, "generation", generation, "latency", time.Since(start)}
+	c.record(ctx, audit.Record{Op: audit.OpDelete, Object: objectName, Generation: generation}, err)
+	if err != nil {
+		c.logger.InfoContext(ctx, "delete failed", append(attrs, "error", err)...)
+		if generation != 0 && isPreconditionFailed(err) {
+			return fmt.Errorf("failed to delete %q generation %d: %w", objectName, generation, ErrGenerationMismatch)
+		}
 		return fmt.Errorf("failed to delete from GCS: %w", err)
 	}
+	c.logger.InfoContext(ctx, "deleted object", attrs...)
 
 	return nil
 }
 
+// Stat returns the attributes of an object.
+func (c *Client) Stat(ctx context.Context, filename string) (*Object, error) {
+	attrs, err := c.object(c.objectName(filename)).Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object attributes: %w", err)
+	}
+	return newObject(c, attrs), nil
+}
+
 // CheckBucket checks if the bucket exists and is accessible.
 func (c *Client) CheckBucket(ctx context.Context) error {
-	_, err := c.client.Bucket(c.bucket).Attrs(ctx)
+	_, err := c.bucketHandle().Attrs(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to access bucket %q: %w", c.bucket, err)
 	}
 	return nil
 }
 
+// RequesterPays reports whether the bucket is requester-pays.
+func (c *Client) RequesterPays(ctx context.Context) (bool, error) {
+	attrs, err := c.bucketHandle().Attrs(ctx)
+	if err != nil {
+		if IsRequesterPaysError(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to access bucket %q: %w", c.bucket, err)
+	}
+	return attrs.RequesterPays, nil
+}
+
+// IsRequesterPaysError reports whether err was caused by accessing a
+// requester-pays bucket without a billing project.
+func IsRequesterPaysError(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "requester pays")
+}
+
 // PublicURL returns the public URL for an object.
 func (c *Client) PublicURL(filename string) string {
 	objectName := c.objectName(filename)
@@ -130,10 +628,74 @@ func (c *Client) PublicURL(filename string) string {
 	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", c.bucket, objectName)
 }
 
+// IsNotFound reports whether err was caused by a missing object.
+func IsNotFound(err error) bool {
+	return errors.Is(err, storage.ErrObjectNotExist)
+}
+
+// shouldRetry decides whether the storage library retries a failed request,
+// like the library does by default, and logs the retries.
+func (c *Client) shouldRetry(err error) bool {
+	retry := storage.ShouldRetry(err)
+	if retry {
+		c.logger.Info("retrying request", "bucket", c.bucket, "error", err)
+	}
+	return retry
+}
+
+// isPreconditionFailed reports whether err is a failed precondition of a
+// conditional request.
+func isPreconditionFailed(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusPreconditionFailed
+	}
+	return status.Code(err) == codes.FailedPrecondition
+}
+
+// bucketHandle returns a handle for the bucket, billing requests to the
+// billing project if one is configured.
+func (c *Client) bucketHandle() *storage.BucketHandle {
+	b := c.client.Bucket(c.bucket)
+	if c.billingProject != "" {
+		b = b.UserProject(c.billingProject)
+	}
+	return b
+}
+
+// object returns a handle for an object, with the customer-supplied
+// encryption key if one is configured. Deleting and listing objects does not
+// need the key, so those use plain handles.
+func (c *Client) object(objectName string) *storage.ObjectHandle {
+	obj := c.bucketHandle().Object(objectName)
+	if c.encryptionKey != nil {
+		obj = obj.Key(c.encryptionKey)
+	}
+	return obj
+}
+
+// encryptionHeaders returns the headers required to read an object encrypted
+// with the customer-supplied encryption key, as "name:value" pairs.
+func (c *Client) encryptionHeaders() []string {
+	if c.encryptionKey == nil {
+		return nil
+	}
+	sum := sha256.Sum256(c.encryptionKey)
+	return []string{
+		"x-goog-encryption-algorithm:AES256",
+		"x-goog-encryption-key:" + base64.StdEncoding.EncodeToString(c.encryptionKey),
+		"x-goog-encryption-key-sha256:" + base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
 // objectName returns the full object name with prefix.
+// A prefix without a trailing slash is treated as a directory.
 func (c *Client) objectName(filename string) string {
 	if c.prefix == "" {
 		return filename
 	}
-	return c.prefix + filename
+	if strings.HasSuffix(c.prefix, "/") {
+		return c.prefix + filename
+	}
+	return c.prefix + "/" + filename
 }
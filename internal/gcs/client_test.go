@@ -1,8 +1,25 @@
 package gcs
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestClient_objectName(t *testing.T) {
@@ -28,7 +45,7 @@ func TestClient_objectName(t *testing.T) {
 			name:     "with prefix no trailing slash",
 			prefix:   "images",
 			filename: "test-file",
-			want:     "imagestest-file",
+			want:     "images/test-file",
 		},
 	}
 
@@ -108,3 +125,188 @@ func TestClient_PublicURL_Emulator(t *testing.T) {
 		t.Errorf("PublicURL() = %q, want %q", got, want)
 	}
 }
+
+func TestClient_encryptionHeaders(t *testing.T) {
+	c := &Client{}
+	if got := c.encryptionHeaders(); got != nil {
+		t.Errorf("encryptionHeaders() without key = %v, want nil", got)
+	}
+
+	// An all-zero key with its base64 encoding and SHA-256 hash.
+	key := make([]byte, 32)
+	c = &Client{encryptionKey: key}
+	want := []string{
+		"x-goog-encryption-algorithm:AES256",
+		"x-goog-encryption-key:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		"x-goog-encryption-key-sha256:Zmh6rfhivXdsj8GLjp+OIAiXFIVu4jOzkCpZHQ1fKSU=",
+	}
+	got := c.encryptionHeaders()
+	if len(got) != len(want) {
+		t.Fatalf("encryptionHeaders() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("encryptionHeaders()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestIsPreconditionFailed(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "precondition failed",
+			err:  fmt.Errorf("wrapped: %w", &googleapi.Error{Code: http.StatusPreconditionFailed}),
+			want: true,
+		},
+		{
+			name: "gRPC failed precondition",
+			err:  status.Error(codes.FailedPrecondition, "precondition failed"),
+			want: true,
+		},
+		{
+			name: "not found",
+			err:  &googleapi.Error{Code: http.StatusNotFound},
+			want: false,
+		},
+		{
+			name: "other error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPreconditionFailed(tt.err); got != tt.want {
+				t.Errorf("isPreconditionFailed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_SignedURL_BillingProject(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	keyJSON, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "sa@example.iam.gserviceaccount.com",
+		"private_key":  string(pemKey),
+	})
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+
+	c, err := NewClient(context.Background(), "my-bucket", "deck/", "",
+		WithCredentialsJSON(keyJSON), WithBillingProject("billing-project"))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	signed, err := c.SignedURL("abc-123", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL() error = %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("SignedURL() = %q is not a URL: %v", signed, err)
+	}
+	if got := u.Query().Get("userProject"); got != "billing-project" {
+		t.Errorf("SignedURL() userProject = %q, want %q", got, "billing-project")
+	}
+	if u.Query().Get("X-Goog-Signature") == "" {
+		t.Errorf("SignedURL() = %q, want a signed URL", signed)
+	}
+}
+
+func TestIsRequesterPaysError(t *testing.T) {
+	err := fmt.Errorf("failed to access bucket: %w", &googleapi.Error{
+		Code:    http.StatusBadRequest,
+		Message: "Bucket is a requester pays bucket but no user project provided.",
+	})
+	if !IsRequesterPaysError(err) {
+		t.Errorf("IsRequesterPaysError(%v) = false, want true", err)
+	}
+	if IsRequesterPaysError(errors.New("not found")) || IsRequesterPaysError(nil) {
+		t.Error("IsRequesterPaysError() = true for an unrelated error")
+	}
+}
+
+// logRecord is a record logged by the client with a JSON handler.
+type logRecord struct {
+	Level      string
+	Msg        string
+	Bucket     string
+	Object     string
+	Bytes      int64
+	Latency    time.Duration
+	ID         string
+	Generation int64
+	Cached     bool
+	Error      string
+}
+
+// logRecords parses the JSON records logged to buf.
+func logRecords(t *testing.T, buf *bytes.Buffer) []logRecord {
+	t.Helper()
+	var records []logRecord
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r logRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("log output: %v", err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestClient_Logs(t *testing.T) {
+	f := newFakeServer(t)
+	var buf bytes.Buffer
+	c := newTestClient(t, f, WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	ctx := context.Background()
+	content := []byte("image")
+
+	// The first request fails with 503 and is retried.
+	f.failures = 1
+	res, err := c.Upload(ctx, "file-1", bytes.NewReader(content), "image/png")
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	records := logRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("logged %d records for a retried upload, want 2: %+v", len(records), records)
+	}
+	if r := records[0]; r.Level != "INFO" || r.Msg != "retrying request" || r.Bucket != "test-bucket" || !strings.Contains(r.Error, "503") {
+		t.Errorf("retry record = %+v", r)
+	}
+	if r := records[1]; r.Level != "INFO" || r.Msg != "uploaded object" || r.Bucket != "test-bucket" || r.Object != "prefix/file-1" ||
+		r.Bytes != int64(len(content)) || r.Latency <= 0 || r.ID != "file-1" || r.Generation != res.Generation || r.Cached {
+		t.Errorf("upload record = %+v", r)
+	}
+
+	if err := c.DeleteGeneration(ctx, "file-1", res.Generation); err != nil {
+		t.Fatalf("DeleteGeneration() error = %v", err)
+	}
+	if err := c.DeleteGeneration(ctx, "file-1", 0); !IsNotFound(err) {
+		t.Fatalf("DeleteGeneration() of a deleted object error = %v, want not found", err)
+	}
+	records = logRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("logged %d records for two deletes, want 2: %+v", len(records), records)
+	}
+	if r := records[0]; r.Level != "INFO" || r.Msg != "deleted object" || r.Object != "prefix/file-1" || r.Generation != res.Generation || r.Latency <= 0 {
+		t.Errorf("delete record = %+v", r)
+	}
+	if r := records[1]; r.Level != "INFO" || r.Msg != "delete failed" || r.Object != "prefix/file-1" || r.Error == "" {
+		t.Errorf("failed delete record = %+v", r)
+	}
+}
@@ -3,6 +3,7 @@ package gcs
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"testing"
@@ -46,14 +47,17 @@ func TestIntegration_UploadAndDelete(t *testing.T) {
 	filename := "test-file-123"
 	contentType := "image/png"
 
-	url, err := client.Upload(ctx, filename, bytes.NewReader(testData), contentType)
+	res, err := client.Upload(ctx, filename, bytes.NewReader(testData), contentType)
 	if err != nil {
 		t.Fatalf("Upload() error = %v", err)
 	}
 
 	expectedURL := "http://localhost:4443/" + testBucket + "/test-prefix/" + filename
-	if url != expectedURL {
-		t.Errorf("Upload() URL = %q, want %q", url, expectedURL)
+	if res.URL != expectedURL {
+		t.Errorf("Upload() URL = %q, want %q", res.URL, expectedURL)
+	}
+	if got, want := res.Checksums.CRC32CBase64(), "WyHPnw=="; got != want {
+		t.Errorf("Upload() crc32c = %q, want %q", got, want)
 	}
 
 	// Test Delete
@@ -67,6 +71,153 @@ func TestIntegration_UploadAndDelete(t *testing.T) {
 	}
 }
 
+func TestIntegration_MetadataAndList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	if err := waitForEmulator(30 * time.Second); err != nil {
+		t.Fatalf("emulator not ready: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := createBucket(ctx, testBucket); err != nil {
+		t.Fatalf("failed to create bucket: %v", err)
+	}
+
+	alice, err := NewClientWithEndpoint(ctx, testBucket, "list-prefix/", "", testEndpoint,
+		WithMetadata(map[string]string{MetadataUser: "alice", "team": "sales"}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer alice.Close()
+
+	bob, err := NewClientWithEndpoint(ctx, testBucket, "list-prefix/", "", testEndpoint,
+		WithMetadata(map[string]string{MetadataUser: "bob"}))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer bob.Close()
+
+	if _, err := alice.Upload(ctx, "alice-file", bytes.NewReader([]byte("a")), "image/png"); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	defer alice.Delete(ctx, "alice-file")
+	if _, err := bob.Upload(ctx, "bob-file", bytes.NewReader([]byte("b")), "image/png"); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	defer bob.Delete(ctx, "bob-file")
+
+	objects, err := alice.List(ctx, ListOptions{Metadata: map[string]string{MetadataUser: "alice"}})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 1 || objects[0].ID != "alice-file" {
+		t.Fatalf("List() = %+v, want only alice-file", objects)
+	}
+	if objects[0].Metadata["team"] != "sales" {
+		t.Errorf("Metadata[team] = %q, want %q", objects[0].Metadata["team"], "sales")
+	}
+
+	objects, err = alice.List(ctx, ListOptions{CreatedBefore: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("List() = %+v, want no objects created over an hour ago", objects)
+	}
+}
+
+func TestIntegration_ObjectAttrs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	if err := waitForEmulator(30 * time.Second); err != nil {
+		t.Fatalf("emulator not ready: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := createBucket(ctx, testBucket); err != nil {
+		t.Fatalf("failed to create bucket: %v", err)
+	}
+
+	client, err := NewClientWithEndpoint(ctx, testBucket, "attrs-prefix/", "", testEndpoint,
+		WithCacheControl("no-store"),
+		WithContentDisposition("inline"),
+		WithStorageClass("nearline"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	filename := "attrs-file"
+	if _, err := client.Upload(ctx, filename, bytes.NewReader([]byte("data")), "image/png"); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	defer client.Delete(ctx, filename)
+
+	attrs, err := client.client.Bucket(testBucket).Object(client.objectName(filename)).Attrs(ctx)
+	if err != nil {
+		t.Fatalf("Attrs() error = %v", err)
+	}
+	if attrs.CacheControl != "no-store" {
+		t.Errorf("CacheControl = %q, want %q", attrs.CacheControl, "no-store")
+	}
+	if attrs.ContentDisposition != "inline" {
+		t.Errorf("ContentDisposition = %q, want %q", attrs.ContentDisposition, "inline")
+	}
+	if attrs.StorageClass != "NEARLINE" {
+		t.Errorf("StorageClass = %q, want %q", attrs.StorageClass, "NEARLINE")
+	}
+}
+
+func TestIntegration_Preconditions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	if err := waitForEmulator(30 * time.Second); err != nil {
+		t.Fatalf("emulator not ready: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := createBucket(ctx, testBucket); err != nil {
+		t.Fatalf("failed to create bucket: %v", err)
+	}
+
+	client, err := NewClientWithEndpoint(ctx, testBucket, "precondition-prefix/", "", testEndpoint)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	filename := "precondition-file"
+	res, err := client.Upload(ctx, filename, bytes.NewReader([]byte("first")), "image/png")
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	defer client.Delete(ctx, filename)
+	if res.Generation == 0 {
+		t.Error("Upload() Generation = 0, want non-zero")
+	}
+
+	if _, err := client.Upload(ctx, filename, bytes.NewReader([]byte("second")), "image/png"); !errors.Is(err, ErrObjectExists) {
+		t.Errorf("Upload() of an existing name error = %v, want %v", err, ErrObjectExists)
+	}
+
+	if err := client.DeleteGeneration(ctx, filename, res.Generation+1); !errors.Is(err, ErrGenerationMismatch) {
+		t.Errorf("DeleteGeneration() with another generation error = %v, want %v", err, ErrGenerationMismatch)
+	}
+	if err := client.DeleteGeneration(ctx, filename, res.Generation); err != nil {
+		t.Errorf("DeleteGeneration() error = %v", err)
+	}
+}
+
 func waitForEmulator(timeout time.Duration) error {
 	client := &http.Client{Timeout: 1 * time.Second}
 	deadline := time.Now().Add(timeout)
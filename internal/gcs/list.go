@@ -0,0 +1,118 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// Standard metadata keys set on every object uploaded by reprint.
+const (
+	MetadataUser    = "reprint-user"
+	MetadataHost    = "reprint-host"
+	MetadataVersion = "reprint-version"
+	MetadataDeck    = "reprint-deck"
+	MetadataSession = "reprint-session"
+)
+
+// Object describes an object under the client prefix.
+type Object struct {
+	ID       string // object name relative to the client prefix, as accepted by Delete
+	Name     string // full object name
+	Size     int64
+	Created  time.Time
+	Metadata map[string]string
+	// Generation identifies this version of the object, as accepted by DeleteGeneration.
+	Generation int64
+	// KMSKeyName is the Cloud KMS key version that encrypts the object, if any.
+	KMSKeyName string
+}
+
+// ListOptions filters the objects returned by List.
+type ListOptions struct {
+	// Prefix is an additional prefix, relative to the client prefix.
+	Prefix string
+	// Metadata lists key/value pairs that objects must all have.
+	Metadata map[string]string
+	// CreatedBefore, if set, excludes objects created at or after it.
+	CreatedBefore time.Time
+	// UploadedByReprint excludes objects without the MetadataVersion
+	// metadata, which reprint sets on every upload.
+	UploadedByReprint bool
+}
+
+// match reports whether an object is selected by opts.
+func (opts ListOptions) match(attrs *storage.ObjectAttrs) bool {
+	if !opts.CreatedBefore.IsZero() && !attrs.Created.Before(opts.CreatedBefore) {
+		return false
+	}
+	if _, ok := attrs.Metadata[MetadataVersion]; opts.UploadedByReprint && !ok {
+		return false
+	}
+	return matchMetadata(attrs.Metadata, opts.Metadata)
+}
+
+// List returns the objects under the client prefix that match opts.
+func (c *Client) List(ctx context.Context, opts ListOptions) ([]Object, error) {
+	query := &storage.Query{Prefix: c.objectName(opts.Prefix)}
+	if err := query.SetAttrSelection([]string{"Name", "Size", "Generation", "Created", "Metadata", "KMSKeyName"}); err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var objects []Object
+	it := c.bucketHandle().Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		if !opts.match(attrs) {
+			continue
+		}
+		objects = append(objects, *newObject(c, attrs))
+	}
+	return objects, nil
+}
+
+// newObject converts object attributes to an Object.
+func newObject(c *Client, attrs *storage.ObjectAttrs) *Object {
+	return &Object{
+		ID:         c.objectID(attrs.Name),
+		Name:       attrs.Name,
+		Size:       attrs.Size,
+		Generation: attrs.Generation,
+		Created:    attrs.Created,
+		Metadata:   attrs.Metadata,
+		KMSKeyName: attrs.KMSKeyName,
+	}
+}
+
+// objectID returns the object id for a full object name; the inverse of objectName.
+func (c *Client) objectID(name string) string {
+	if c.prefix == "" {
+		return name
+	}
+	prefix := c.prefix
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return strings.TrimPrefix(name, prefix)
+}
+
+// matchMetadata reports whether metadata contains all pairs in filter.
+func matchMetadata(metadata, filter map[string]string) bool {
+	for k, v := range filter {
+		if got, ok := metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package gcs
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestClient_objectID(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		objectName string
+		want       string
+	}{
+		{name: "without prefix", prefix: "", objectName: "deck/abc-123", want: "deck/abc-123"},
+		{name: "with prefix", prefix: "deck/", objectName: "deck/abc-123", want: "abc-123"},
+		{name: "with prefix no trailing slash", prefix: "deck", objectName: "deck/abc-123", want: "abc-123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{prefix: tt.prefix}
+			got := c.objectID(tt.objectName)
+			if got != tt.want {
+				t.Errorf("objectID() = %q, want %q", got, tt.want)
+			}
+			if name := c.objectName(got); name != tt.objectName {
+				t.Errorf("objectName(objectID()) = %q, want %q", name, tt.objectName)
+			}
+		})
+	}
+}
+
+func TestMatchMetadata(t *testing.T) {
+	metadata := map[string]string{
+		MetadataUser: "alice",
+		MetadataDeck: "intro",
+	}
+
+	tests := []struct {
+		name   string
+		filter map[string]string
+		want   bool
+	}{
+		{name: "no filter", filter: nil, want: true},
+		{name: "match one", filter: map[string]string{MetadataUser: "alice"}, want: true},
+		{name: "match all", filter: map[string]string{MetadataUser: "alice", MetadataDeck: "intro"}, want: true},
+		{name: "different value", filter: map[string]string{MetadataUser: "bob"}, want: false},
+		{name: "missing key", filter: map[string]string{MetadataHost: "laptop"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchMetadata(metadata, tt.filter); got != tt.want {
+				t.Errorf("matchMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListOptions_match(t *testing.T) {
+	now := time.Now()
+	uploaded := &storage.ObjectAttrs{Created: now.Add(-2 * time.Hour), Metadata: map[string]string{MetadataVersion: "v1.0.0", MetadataDeck: "intro"}}
+	foreign := &storage.ObjectAttrs{Created: now.Add(-2 * time.Hour)}
+
+	tests := []struct {
+		name  string
+		opts  ListOptions
+		attrs *storage.ObjectAttrs
+		want  bool
+	}{
+		{name: "no options", attrs: foreign, want: true},
+		{name: "created before", opts: ListOptions{CreatedBefore: now.Add(-time.Hour)}, attrs: uploaded, want: true},
+		{name: "created after", opts: ListOptions{CreatedBefore: now.Add(-3 * time.Hour)}, attrs: uploaded, want: false},
+		{name: "metadata", opts: ListOptions{Metadata: map[string]string{MetadataDeck: "intro"}}, attrs: uploaded, want: true},
+		{name: "uploaded by reprint", opts: ListOptions{UploadedByReprint: true}, attrs: uploaded, want: true},
+		{name: "not uploaded by reprint", opts: ListOptions{UploadedByReprint: true}, attrs: foreign, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.match(tt.attrs); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
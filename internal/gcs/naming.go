@@ -0,0 +1,103 @@
+package gcs
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateBucketName checks name against the GCS bucket naming rules.
+// See https://cloud.google.com/storage/docs/buckets#naming
+func ValidateBucketName(name string) error {
+	if len(name) < 3 {
+		return fmt.Errorf("invalid bucket name %q: must be at least 3 characters", name)
+	}
+	if strings.Contains(name, ".") {
+		if len(name) > 222 {
+			return fmt.Errorf("invalid bucket name %q: must be at most 222 characters", name)
+		}
+		for _, component := range strings.Split(name, ".") {
+			if len(component) > 63 {
+				return fmt.Errorf("invalid bucket name %q: each dot-separated component must be at most 63 characters", name)
+			}
+		}
+	} else if len(name) > 63 {
+		return fmt.Errorf("invalid bucket name %q: must be at most 63 characters", name)
+	}
+
+	for _, r := range name {
+		if !isLowerAlnum(r) && r != '-' && r != '_' && r != '.' {
+			return fmt.Errorf("invalid bucket name %q: may contain only lowercase letters, digits, dashes, underscores, and dots", name)
+		}
+	}
+	if !isLowerAlnum(rune(name[0])) || !isLowerAlnum(rune(name[len(name)-1])) {
+		return fmt.Errorf("invalid bucket name %q: must start and end with a letter or digit", name)
+	}
+	if net.ParseIP(name) != nil {
+		return fmt.Errorf("invalid bucket name %q: must not be an IP address", name)
+	}
+	if strings.HasPrefix(name, "goog") {
+		return fmt.Errorf("invalid bucket name %q: must not begin with \"goog\"", name)
+	}
+	if strings.Contains(name, "google") || strings.Contains(name, "g00gle") {
+		return fmt.Errorf("invalid bucket name %q: must not contain \"google\"", name)
+	}
+	return nil
+}
+
+func isLowerAlnum(r rune) bool {
+	return ('a' <= r && r <= 'z') || ('0' <= r && r <= '9')
+}
+
+// ValidateObjectName checks name against the GCS object naming rules.
+// See https://cloud.google.com/storage/docs/objects#naming
+func ValidateObjectName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("invalid object name: must not be empty")
+	case len(name) > 1024:
+		return fmt.Errorf("invalid object name %q: must be at most 1024 bytes", name)
+	case !utf8.ValidString(name):
+		return fmt.Errorf("invalid object name %q: must be valid UTF-8", name)
+	case strings.ContainsAny(name, "\r\n"):
+		return fmt.Errorf("invalid object name %q: must not contain carriage return or line feed", name)
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid object name %q", name)
+	case strings.HasPrefix(name, ".well-known/acme-challenge/"):
+		return fmt.Errorf("invalid object name %q: must not start with \".well-known/acme-challenge/\"", name)
+	}
+	return nil
+}
+
+// storageClasses are the storage classes accepted by GCS, including legacy ones.
+var storageClasses = []string{
+	"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE",
+	"MULTI_REGIONAL", "REGIONAL", "DURABLE_REDUCED_AVAILABILITY",
+}
+
+// ValidateStorageClass checks that class is a GCS storage class.
+// Empty means the bucket default and is valid.
+func ValidateStorageClass(class string) error {
+	if class == "" {
+		return nil
+	}
+	for _, c := range storageClasses {
+		if strings.EqualFold(class, c) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid storage class %q: must be one of %s", class, strings.Join(storageClasses[:4], ", "))
+}
+
+var kmsKeyNamePattern = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// ValidateKMSKeyName checks that name is a Cloud KMS key resource name.
+// Empty means no customer-managed key and is valid.
+func ValidateKMSKeyName(name string) error {
+	if name == "" || kmsKeyNamePattern.MatchString(name) {
+		return nil
+	}
+	return fmt.Errorf("invalid KMS key %q: must be projects/PROJECT/locations/LOCATION/keyRings/RING/cryptoKeys/KEY", name)
+}
@@ -0,0 +1,100 @@
+package gcs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateBucketName(t *testing.T) {
+	tests := []struct {
+		name    string
+		bucket  string
+		wantErr bool
+	}{
+		{name: "simple", bucket: "my-bucket"},
+		{name: "underscores and digits", bucket: "deck_images_2024"},
+		{name: "dotted", bucket: "images.example.com"},
+		{name: "too short", bucket: "ab", wantErr: true},
+		{name: "too long", bucket: strings.Repeat("a", 64), wantErr: true},
+		{name: "dotted component too long", bucket: strings.Repeat("a", 64) + ".example.com", wantErr: true},
+		{name: "uppercase", bucket: "My-Bucket", wantErr: true},
+		{name: "invalid character", bucket: "my/bucket", wantErr: true},
+		{name: "starts with dash", bucket: "-bucket", wantErr: true},
+		{name: "ends with dot", bucket: "bucket.", wantErr: true},
+		{name: "IP address", bucket: "192.168.5.4", wantErr: true},
+		{name: "goog prefix", bucket: "goog-bucket", wantErr: true},
+		{name: "contains google", bucket: "my-google-bucket", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBucketName(tt.bucket)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBucketName(%q) error = %v, wantErr %v", tt.bucket, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateObjectName(t *testing.T) {
+	tests := []struct {
+		name    string
+		object  string
+		wantErr bool
+	}{
+		{name: "simple", object: "deck/alice/2024-01-02/abc-123"},
+		{name: "unicode", object: "デッキ/abc"},
+		{name: "empty", object: "", wantErr: true},
+		{name: "too long", object: strings.Repeat("a", 1025), wantErr: true},
+		{name: "invalid UTF-8", object: "deck/\xff", wantErr: true},
+		{name: "line feed", object: "deck\n/abc", wantErr: true},
+		{name: "dot", object: ".", wantErr: true},
+		{name: "dot dot", object: "..", wantErr: true},
+		{name: "acme challenge", object: ".well-known/acme-challenge/abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateObjectName(tt.object)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateObjectName(%q) error = %v, wantErr %v", tt.object, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateStorageClass(t *testing.T) {
+	for _, class := range []string{"", "STANDARD", "nearline", "Coldline", "ARCHIVE", "REGIONAL"} {
+		if err := ValidateStorageClass(class); err != nil {
+			t.Errorf("ValidateStorageClass(%q) error = %v", class, err)
+		}
+	}
+	for _, class := range []string{"STANDART", "hot"} {
+		if err := ValidateStorageClass(class); err == nil {
+			t.Errorf("ValidateStorageClass(%q) should fail", class)
+		}
+	}
+}
+
+func TestValidateKMSKeyName(t *testing.T) {
+	valid := []string{
+		"",
+		"projects/my-project/locations/asia-northeast1/keyRings/reprint/cryptoKeys/images",
+	}
+	for _, name := range valid {
+		if err := ValidateKMSKeyName(name); err != nil {
+			t.Errorf("ValidateKMSKeyName(%q) error = %v", name, err)
+		}
+	}
+
+	invalid := []string{
+		"images",
+		"projects/my-project/locations/asia-northeast1/keyRings/reprint",
+		"projects/my-project/locations/asia-northeast1/keyRings/reprint/cryptoKeys/images/cryptoKeyVersions/1",
+	}
+	for _, name := range invalid {
+		if err := ValidateKMSKeyName(name); err == nil {
+			t.Errorf("ValidateKMSKeyName(%q) should fail", name)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+const (
+	// defaultChunkSize matches the default of storage.Writer.
+	defaultChunkSize = 16 << 20
+	// chunkAlignment is the granularity of resumable upload chunks.
+	chunkAlignment = 256 << 10
+	// defaultChunkRetryDeadline matches the default of storage.Writer.
+	defaultChunkRetryDeadline = 32 * time.Second
+
+	// sessionTTL is how long a persisted session is resumed. GCS expires
+	// resumable upload sessions after a week.
+	sessionTTL = 6 * 24 * time.Hour
+)
+
+// errSessionGone is returned when a resumable upload session no longer exists.
+var errSessionGone = errors.New("resumable upload session expired")
+
+// uploadSession is a resumable upload session persisted between runs.
+type uploadSession struct {
+	URI     string    `json:"uri"`
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+// objectResource is the part of the JSON API object resource returned when
+// a resumable upload completes.
+type objectResource struct {
+	Generation string `json:"generation"`
+	CRC32C     string `json:"crc32c"`
+	MD5Hash    string `json:"md5Hash"`
+}
+
+// uploadResumable uploads content with metadata using the JSON API resumable
+// upload protocol, persisting the session so that a later upload of the same
+// content resumes it.
+func (c *Client) uploadResumable(ctx context.Context, filename string, content []byte, sums Checksums, contentType string, metadata map[string]string) (*UploadResult, error) {
+	hc, _, err := htransport.NewClient(ctx, append(c.clientOpts, option.WithScopes(storage.ScopeReadWrite))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	total := int64(len(content))
+	path := filepath.Join(c.sessionDir, c.sessionKey(content, contentType, metadata)+".json")
+
+	var obj *objectResource
+	offset := int64(0)
+	session, ok := loadSession(path)
+	if ok {
+		offset, obj, err = c.querySession(ctx, hc, session.URI, total)
+		c.logger.DebugContext(ctx, "resuming upload session", "bucket", c.bucket, "object", c.objectName(session.ID), "offset", offset, "error", err)
+		if errors.Is(err, errSessionGone) {
+			os.Remove(path)
+			ok = false
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		uri, err := c.startSession(ctx, hc, c.objectName(filename), contentType, metadata, sums, total)
+		if err != nil {
+			return nil, err
+		}
+		session = &uploadSession{URI: uri, ID: filename, Created: time.Now()}
+		if err := saveSession(path, session); err != nil {
+			return nil, err
+		}
+	}
+
+	chunkSize := c.resumableChunkSize(total)
+	for obj == nil {
+		end := min(offset+chunkSize, total)
+		offset, obj, err = c.putChunkWithRetry(ctx, hc, session.URI, content, offset, end)
+		if err != nil {
+			if errors.Is(err, errSessionGone) {
+				os.Remove(path)
+			}
+			return nil, err
+		}
+		if obj != nil {
+			offset = total
+		}
+		if c.progress != nil && total > chunkSize {
+			c.progress(offset, total)
+		}
+	}
+	os.Remove(path)
+
+	generation, err := strconv.ParseInt(obj.Generation, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generation %q in upload response", obj.Generation)
+	}
+	crc32c, md5sum, err := decodeChecksums(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksums(sums, crc32c, md5sum); err != nil {
+		objectName := c.objectName(session.ID)
+		if delErr := c.bucketHandle().Object(objectName).If(storage.Conditions{GenerationMatch: generation}).Delete(ctx); delErr != nil {
+			return nil, fmt.Errorf("%w (failed to delete the corrupted object: %v)", err, delErr)
+		}
+		return nil, err
+	}
+
+	signedURL, err := c.signURL(ctx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadResult{ID: session.ID, URL: signedURL, Generation: generation, Checksums: sums}, nil
+}
+
+// sessionKey identifies uploads of the same content with the same settings
+// and metadata. The metadata includes the deck run's session, so that an
+// upload is never reused by another run, which may delete it.
+func (c *Client) sessionKey(content []byte, contentType string, metadata map[string]string) string {
+	h := sha256.New()
+	for _, s := range []string{c.endpoint, c.bucket, c.prefix, contentType, c.kmsKey, string(c.encryptionKey)} {
+		io.WriteString(h, s)
+		h.Write([]byte{0})
+	}
+	for _, k := range slices.Sorted(maps.Keys(metadata)) {
+		fmt.Fprintf(h, "%q=%q", k, metadata[k])
+		h.Write([]byte{0})
+	}
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// resumableChunkSize returns the chunk size for a resumable upload of total
+// bytes, rounded up to a multiple of 256 KiB as the protocol requires.
+func (c *Client) resumableChunkSize(total int64) int64 {
+	size := int64(c.chunkSize)
+	switch {
+	case size < 0:
+		size = defaultChunkSize
+	case size == 0:
+		return max(total, 1)
+	}
+	return (size + chunkAlignment - 1) / chunkAlignment * chunkAlignment
+}
+
+// uploadBaseURL returns the base URL of the JSON API upload endpoint.
+func (c *Client) uploadBaseURL() string {
+	if c.endpoint != "" {
+		return strings.Replace(c.endpoint, "/storage/v1/", "/upload/storage/v1/", 1)
+	}
+	return "https://storage.googleapis.com/upload/storage/v1/"
+}
+
+// startSession initiates a resumable upload and returns the session URI.
+func (c *Client) startSession(ctx context.Context, hc *http.Client, objectName, contentType string, metadata map[string]string, sums Checksums, total int64) (string, error) {
+	resource := map[string]any{
+		"name":        objectName,
+		"contentType": contentType,
+		"crc32c":      sums.CRC32CBase64(),
+		"md5Hash":     sums.MD5Base64(),
+	}
+	if len(metadata) > 0 {
+		resource["metadata"] = metadata
+	}
+	// Empty values mean the GCS default and must be omitted.
+	for k, v := range map[string]string{
+		"cacheControl":       c.cacheControl,
+		"contentDisposition": c.contentDisposition,
+		"storageClass":       c.storageClass,
+		"kmsKeyName":         c.kmsKey,
+	} {
+		if v != "" {
+			resource[k] = v
+		}
+	}
+	body, err := json.Marshal(resource)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{"uploadType": {"resumable"}, "ifGenerationMatch": {"0"}}
+	if c.billingProject != "" {
+		query.Set("userProject", c.billingProject)
+	}
+	u := c.uploadBaseURL() + "b/" + url.PathEscape(c.bucket) + "/o?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Type", contentType)
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(total, 10))
+	c.setEncryptionHeaders(req)
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to start resumable upload: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to start resumable upload: %w", responseError(resp, objectName))
+	}
+	uri := resp.Header.Get("Location")
+	if uri == "" {
+		return "", fmt.Errorf("failed to start resumable upload: no session URI in response")
+	}
+	return uri, nil
+}
+
+// querySession returns how many bytes of a session GCS has persisted, or the
+// object if the upload already completed.
+func (c *Client) querySession(ctx context.Context, hc *http.Client, uri string, total int64) (int64, *objectResource, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uri, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+	c.setEncryptionHeaders(req)
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to query resumable upload: %w", err)
+	}
+	defer resp.Body.Close()
+	return parseSessionResponse(resp)
+}
+
+// putChunkWithRetry uploads content[offset:end], retrying transient failures
+// until the chunk retry deadline. It returns the new offset, or the object
+// once the upload completes.
+func (c *Client) putChunkWithRetry(ctx context.Context, hc *http.Client, uri string, content []byte, offset, end int64) (int64, *objectResource, error) {
+	deadline := c.chunkRetryDeadline
+	if deadline <= 0 {
+		deadline = defaultChunkRetryDeadline
+	}
+	stop := time.Now().Add(deadline)
+	total := int64(len(content))
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		next, obj, err := c.putChunk(ctx, hc, uri, content[offset:end], offset, total)
+		if err == nil || !isRetryable(err) || time.Now().Add(backoff).After(stop) {
+			return next, obj, err
+		}
+		c.logger.InfoContext(ctx, "retrying upload chunk", "bucket", c.bucket, "offset", offset, "attempt", attempt, "backoff", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 16*time.Second)
+
+		// Part of the chunk may have been persisted before the failure.
+		if next, obj, err := c.querySession(ctx, hc, uri, total); err == nil {
+			if obj != nil || next >= end {
+				return next, obj, nil
+			}
+			offset = next
+		}
+	}
+}
+
+// putChunk uploads one chunk starting at offset.
+func (c *Client) putChunk(ctx context.Context, hc *http.Client, uri string, chunk []byte, offset, total int64) (int64, *objectResource, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uri, bytes.NewReader(chunk))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(chunk) == 0 {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+	} else {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(chunk))-1, total))
+	}
+	c.setEncryptionHeaders(req)
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return 0, nil, &retryableError{err}
+	}
+	defer resp.Body.Close()
+	return parseSessionResponse(resp)
+}
+
+// setEncryptionHeaders adds the customer-supplied encryption key headers.
+func (c *Client) setEncryptionHeaders(req *http.Request) {
+	for _, h := range c.encryptionHeaders() {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Set(name, value)
+	}
+}
+
+// parseSessionResponse interprets a response to a resumable upload request.
+func parseSessionResponse(resp *http.Response) (int64, *objectResource, error) {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		var obj objectResource
+		if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+			return 0, nil, fmt.Errorf("failed to decode upload response: %w", err)
+		}
+		return 0, &obj, nil
+	case http.StatusPermanentRedirect:
+		// "Range: bytes=0-N" lists the persisted bytes; it is absent if none are.
+		r := resp.Header.Get("Range")
+		if r == "" {
+			return 0, nil, nil
+		}
+		_, last, ok := strings.Cut(strings.TrimPrefix(r, "bytes="), "-")
+		n, err := strconv.ParseInt(last, 10, 64)
+		if !ok || err != nil {
+			return 0, nil, fmt.Errorf("invalid Range %q in upload response", r)
+		}
+		return n + 1, nil, nil
+	case http.StatusNotFound, http.StatusGone:
+		return 0, nil, errSessionGone
+	case http.StatusPreconditionFailed:
+		return 0, nil, ErrObjectExists
+	default:
+		err := responseError(resp, "")
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return 0, nil, &retryableError{err}
+		}
+		return 0, nil, err
+	}
+}
+
+// responseError converts an unexpected response to an error. objectName is
+// used for the error of a failed create-only precondition.
+func responseError(resp *http.Response, objectName string) error {
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("failed to upload %q: %w", objectName, ErrObjectExists)
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected response %s: %s", resp.Status, bytes.TrimSpace(body))
+}
+
+// retryableError marks transient failures of resumable upload requests.
+type retryableError struct{ err error }
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+func isRetryable(err error) bool {
+	var r *retryableError
+	return errors.As(err, &r)
+}
+
+// decodeChecksums decodes the base64 checksums of an object resource.
+func decodeChecksums(obj *objectResource) (uint32, []byte, error) {
+	crc, err := base64.StdEncoding.DecodeString(obj.CRC32C)
+	if err != nil || len(crc) != 4 {
+		return 0, nil, fmt.Errorf("invalid crc32c %q in upload response", obj.CRC32C)
+	}
+	md5sum, err := base64.StdEncoding.DecodeString(obj.MD5Hash)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid md5Hash %q in upload response", obj.MD5Hash)
+	}
+	return binary.BigEndian.Uint32(crc), md5sum, nil
+}
+
+// loadSession reads a persisted session, ignoring missing, corrupt or
+// expired ones.
+func loadSession(path string) (*uploadSession, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var s uploadSession
+	if err := json.Unmarshal(data, &s); err != nil || s.URI == "" || s.ID == "" || time.Since(s.Created) > sessionTTL {
+		os.Remove(path)
+		return nil, false
+	}
+	return &s, true
+}
+
+// saveSession persists a session. The session URI authorizes the upload
+// without further credentials, so the file is only readable by the user.
+func saveSession(path string, s *uploadSession) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to save upload session: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save upload session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeResumableServer implements the resumable upload protocol of the JSON API
+// for a single object.
+type fakeResumableServer struct {
+	mu       sync.Mutex
+	name     string
+	data     []byte
+	failAt   int64   // reject the chunk starting at this offset once, if positive
+	starts   int     // number of sessions started
+	offsets  []int64 // start offsets of received chunks
+	upstream *httptest.Server
+}
+
+func newFakeResumableServer(t *testing.T) *fakeResumableServer {
+	f := &fakeResumableServer{}
+	f.upstream = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.upstream.Close)
+	return f
+}
+
+func (f *fakeResumableServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/test-bucket/o"):
+		var resource struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || r.URL.Query().Get("uploadType") != "resumable" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		f.starts++
+		f.name = resource.Name
+		f.data = nil
+		w.Header().Set("Location", f.upstream.URL+"/session")
+	case r.Method == http.MethodPut && r.URL.Path == "/session":
+		var start, end, total int64
+		cr := r.Header.Get("Content-Range")
+		if _, err := fmt.Sscanf(cr, "bytes */%d", &total); err == nil {
+			f.respond(w, total)
+			return
+		}
+		if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &start, &end, &total); err != nil || start != int64(len(f.data)) {
+			http.Error(w, "bad range", http.StatusBadRequest)
+			return
+		}
+		if f.failAt > 0 && start == f.failAt {
+			f.failAt = 0
+			http.Error(w, "interrupted", http.StatusBadRequest)
+			return
+		}
+		f.offsets = append(f.offsets, start)
+		chunk := new(bytes.Buffer)
+		chunk.ReadFrom(r.Body)
+		f.data = append(f.data, chunk.Bytes()...)
+		f.respond(w, total)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeResumableServer) respond(w http.ResponseWriter, total int64) {
+	if int64(len(f.data)) < total {
+		if len(f.data) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(f.data)-1))
+		}
+		w.WriteHeader(http.StatusPermanentRedirect)
+		return
+	}
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.Checksum(f.data, crc32cTable))
+	sum := md5.Sum(f.data)
+	json.NewEncoder(w).Encode(map[string]string{
+		"name":       f.name,
+		"generation": "42",
+		"crc32c":     base64.StdEncoding.EncodeToString(crc[:]),
+		"md5Hash":    base64.StdEncoding.EncodeToString(sum[:]),
+	})
+}
+
+func newResumableTestClient(t *testing.T, f *fakeResumableServer, dir string, opts ...Option) *Client {
+	opts = append([]Option{WithResumableSessions(dir), WithChunkSize(chunkAlignment)}, opts...)
+	c, err := NewClientWithEndpoint(context.Background(), "test-bucket", "prefix/", "", f.upstream.URL+"/storage/v1/", opts...)
+	if err != nil {
+		t.Fatalf("NewClientWithEndpoint() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient_Upload_Resumable(t *testing.T) {
+	f := newFakeResumableServer(t)
+	dir := t.TempDir()
+
+	var progress []int64
+	c := newResumableTestClient(t, f, dir, WithProgress(func(sent, total int64) {
+		progress = append(progress, sent)
+	}))
+
+	content := bytes.Repeat([]byte("0123456789"), 60<<10) // 600 KiB, three chunks
+	res, err := c.Upload(context.Background(), "file-1", bytes.NewReader(content), "image/png")
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	if res.ID != "file-1" || res.Generation != 42 {
+		t.Errorf("Upload() = %+v, want ID file-1 and generation 42", res)
+	}
+	if f.name != "prefix/file-1" || !bytes.Equal(f.data, content) {
+		t.Errorf("uploaded %q with %d bytes, want prefix/file-1 with %d bytes", f.name, len(f.data), len(content))
+	}
+	want := []int64{chunkAlignment, 2 * chunkAlignment, int64(len(content))}
+	if fmt.Sprint(progress) != fmt.Sprint(want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("session files left after upload: %v", entries)
+	}
+}
+
+func TestClient_Upload_ResumesInterruptedUpload(t *testing.T) {
+	f := newFakeResumableServer(t)
+	f.failAt = chunkAlignment
+	dir := t.TempDir()
+	c := newResumableTestClient(t, f, dir)
+
+	content := bytes.Repeat([]byte("x"), 2*chunkAlignment+1)
+	if _, err := c.Upload(context.Background(), "file-1", bytes.NewReader(content), "image/png"); err == nil {
+		t.Fatal("Upload() should fail when a chunk is rejected")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("session files = %v, want one", entries)
+	}
+
+	// A retried upload of the same content gets a new id, but resumes the
+	// interrupted upload under the original one.
+	res, err := c.Upload(context.Background(), "file-2", bytes.NewReader(content), "image/png")
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if res.ID != "file-1" {
+		t.Errorf("Upload() ID = %q, want %q", res.ID, "file-1")
+	}
+	if f.starts != 1 {
+		t.Errorf("sessions started = %d, want 1", f.starts)
+	}
+	want := []int64{0, chunkAlignment, 2 * chunkAlignment}
+	if fmt.Sprint(f.offsets) != fmt.Sprint(want) {
+		t.Errorf("chunk offsets = %v, want %v", f.offsets, want)
+	}
+	if !bytes.Equal(f.data, content) {
+		t.Errorf("uploaded %d bytes, want %d", len(f.data), len(content))
+	}
+}
+
+func TestClient_resumableChunkSize(t *testing.T) {
+	tests := []struct {
+		chunkSize int
+		total     int64
+		want      int64
+	}{
+		{chunkSize: -1, total: 100, want: defaultChunkSize},
+		{chunkSize: 0, total: 100, want: 100},
+		{chunkSize: 0, total: 0, want: 1},
+		{chunkSize: 1, total: 100, want: chunkAlignment},
+		{chunkSize: chunkAlignment + 1, total: 100, want: 2 * chunkAlignment},
+	}
+
+	for _, tt := range tests {
+		c := &Client{chunkSize: tt.chunkSize}
+		if got := c.resumableChunkSize(tt.total); got != tt.want {
+			t.Errorf("resumableChunkSize(%d) with chunk size %d = %d, want %d", tt.total, tt.chunkSize, got, tt.want)
+		}
+	}
+}
+
+func TestLoadSession_IgnoresCorruptFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+	if _, ok := loadSession(path); ok {
+		t.Error("loadSession() ok = true for a corrupt file")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("loadSession() should remove a corrupt file")
+	}
+}
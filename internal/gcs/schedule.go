@@ -0,0 +1,59 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/minodisk/reprint/internal/audit"
+)
+
+// ScheduleDelete sets the custom time of an object to at, so that a bucket
+// lifecycle rule on daysSinceCustomTime deletes it some time after then.
+// The rule counts whole days, so the delete may come up to a day late. If
+// generation is not zero, it fails with ErrGenerationMismatch unless that is
+// the current generation. The custom time of an object can only move forward.
+func (c *Client) ScheduleDelete(ctx context.Context, filename string, generation int64, at time.Time) error {
+	objectName := c.objectName(filename)
+	obj := c.bucketHandle().Object(objectName)
+	if generation != 0 {
+		obj = obj.If(storage.Conditions{GenerationMatch: generation})
+	}
+
+	ctx, span := c.startSpan(ctx, opScheduleDelete, objectName)
+	start := time.Now()
+	_, err := obj.Update(ctx, storage.ObjectAttrsToUpdate{CustomTime: at})
+	c.endSpan(ctx, span, opScheduleDelete, start, err)
+	c.record(ctx, audit.Record{Op: audit.OpScheduleDelete, Object: objectName, Generation: generation, DeleteAt: at}, err)
+	if err != nil {
+		if generation != 0 && isPreconditionFailed(err) {
+			return fmt.Errorf("failed to schedule deletion of %q generation %d: %w", objectName, generation, ErrGenerationMismatch)
+		}
+		return fmt.Errorf("failed to schedule deletion: %w", err)
+	}
+	c.logger.InfoContext(ctx, "scheduled deletion", "bucket", c.bucket, "object", objectName, "generation", generation, "at", at)
+	return nil
+}
+
+// DeletesByCustomTime reports whether the bucket lifecycle deletes objects
+// by their custom time, as ScheduleDelete relies on.
+func (c *Client) DeletesByCustomTime(ctx context.Context) (bool, error) {
+	attrs, err := c.bucketHandle().Attrs(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to access bucket %q: %w", c.bucket, err)
+	}
+	return deletesByCustomTime(attrs.Lifecycle), nil
+}
+
+// deletesByCustomTime reports whether a lifecycle has a delete rule on
+// daysSinceCustomTime. A rule on zero days reads the same as no condition,
+// so it is not recognized.
+func deletesByCustomTime(lc storage.Lifecycle) bool {
+	for _, rule := range lc.Rules {
+		if rule.Action.Type == storage.DeleteAction && rule.Condition.DaysSinceCustomTime > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestClient_ScheduleDelete(t *testing.T) {
+	var got struct {
+		Path       string
+		Generation string
+		CustomTime string `json:"customTime"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			http.NotFound(w, r)
+			return
+		}
+		got.Path = r.URL.Path
+		got.Generation = r.URL.Query().Get("ifGenerationMatch")
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.Generation == "1" {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return
+		}
+		w.Write([]byte(`{"bucket": "test-bucket", "name": "prefix/file-1"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClientWithEndpoint(context.Background(), "test-bucket", "prefix/", "", srv.URL+"/storage/v1/")
+	if err != nil {
+		t.Fatalf("NewClientWithEndpoint() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	at := time.Date(2024, 3, 5, 9, 30, 0, 0, time.UTC)
+	if err := c.ScheduleDelete(context.Background(), "file-1", 42, at); err != nil {
+		t.Fatalf("ScheduleDelete() error = %v", err)
+	}
+	if got.Path != "/storage/v1/b/test-bucket/o/prefix/file-1" || got.Generation != "42" || got.CustomTime != "2024-03-05T09:30:00Z" {
+		t.Errorf("ScheduleDelete() sent %+v", got)
+	}
+
+	if err := c.ScheduleDelete(context.Background(), "file-1", 1, at); !errors.Is(err, ErrGenerationMismatch) {
+		t.Errorf("ScheduleDelete() of a replaced object error = %v, want ErrGenerationMismatch", err)
+	}
+}
+
+func TestDeletesByCustomTime(t *testing.T) {
+	tests := []struct {
+		name string
+		rule storage.LifecycleRule
+		want bool
+	}{
+		{
+			name: "delete by custom time",
+			rule: storage.LifecycleRule{
+				Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+				Condition: storage.LifecycleCondition{DaysSinceCustomTime: 1},
+			},
+			want: true,
+		},
+		{
+			name: "delete by age",
+			rule: storage.LifecycleRule{
+				Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+				Condition: storage.LifecycleCondition{AgeInDays: 1},
+			},
+		},
+		{
+			name: "storage class change by custom time",
+			rule: storage.LifecycleRule{
+				Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: "NEARLINE"},
+				Condition: storage.LifecycleCondition{DaysSinceCustomTime: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deletesByCustomTime(storage.Lifecycle{Rules: []storage.LifecycleRule{tt.rule}}); got != tt.want {
+				t.Errorf("deletesByCustomTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
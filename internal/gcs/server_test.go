@@ -0,0 +1,199 @@
+package gcs
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer implements the parts of the JSON API that the client uses to
+// upload and delete objects, storing objects in memory.
+type fakeServer struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	objects    map[string]*fakeObject // by object name
+	generation int64                  // generation of the last upload
+	failures   int                    // number of next requests failed with 503
+	corrupt    bool                   // store uploads with their first byte flipped
+}
+
+// fakeObject is an object resource of the JSON API.
+type fakeObject struct {
+	Name        string            `json:"name"`
+	Bucket      string            `json:"bucket"`
+	Size        int64             `json:"size,string"`
+	Generation  int64             `json:"generation,string"`
+	ContentType string            `json:"contentType"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CRC32C      string            `json:"crc32c"`
+	MD5Hash     string            `json:"md5Hash"`
+	TimeCreated time.Time         `json:"timeCreated"`
+
+	data []byte
+	sent Checksums // checksums sent with the upload, if any
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{objects: map[string]*fakeObject{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// object returns the stored object with the full name, or nil.
+func (f *fakeServer) object(name string) *fakeObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.objects[name]
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failures > 0 {
+		f.failures--
+		writeError(w, http.StatusServiceUnavailable)
+		return
+	}
+	name, isObject := strings.CutPrefix(r.URL.Path, "/storage/v1/b/test-bucket/o/")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/test-bucket/o":
+		f.upload(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/test-bucket":
+		json.NewEncoder(w).Encode(map[string]string{"name": "test-bucket"})
+	case isObject && f.objects[name] == nil:
+		writeError(w, http.StatusNotFound)
+	case isObject && !matchGeneration(r, f.objects[name].Generation):
+		writeError(w, http.StatusPreconditionFailed)
+	case isObject && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(f.objects[name])
+	case isObject && r.Method == http.MethodDelete:
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// upload stores an object sent as a multipart upload.
+func (f *fakeServer) upload(w http.ResponseWriter, r *http.Request) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || r.URL.Query().Get("uploadType") != "multipart" {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+	parts := multipart.NewReader(r.Body, params["boundary"])
+	var obj fakeObject
+	part, err := parts.NextPart()
+	if err == nil {
+		err = json.NewDecoder(part).Decode(&obj)
+	}
+	if err == nil {
+		part, err = parts.NextPart()
+	}
+	if err == nil {
+		obj.data, err = io.ReadAll(part)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+	if old := f.objects[obj.Name]; r.URL.Query().Get("ifGenerationMatch") == "0" && old != nil {
+		writeError(w, http.StatusPreconditionFailed)
+		return
+	}
+	if f.corrupt && len(obj.data) > 0 {
+		obj.data[0] ^= 0xff
+	}
+
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.Checksum(obj.data, crc32cTable))
+	sum := md5.Sum(obj.data)
+	// Like GCS, reject content that does not match the checksums sent with it.
+	sentCRC32C, sentMD5 := obj.CRC32C, obj.MD5Hash
+	obj.CRC32C = base64.StdEncoding.EncodeToString(crc[:])
+	obj.MD5Hash = base64.StdEncoding.EncodeToString(sum[:])
+	if (sentCRC32C != "" && sentCRC32C != obj.CRC32C) || (sentMD5 != "" && sentMD5 != obj.MD5Hash) {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+	if b, err := base64.StdEncoding.DecodeString(sentCRC32C); err == nil && len(b) == 4 {
+		obj.sent.CRC32C = binary.BigEndian.Uint32(b)
+	}
+	if sentMD5 != "" {
+		obj.sent.MD5, _ = base64.StdEncoding.DecodeString(sentMD5)
+	}
+
+	f.generation++
+	obj.Bucket = "test-bucket"
+	obj.Size = int64(len(obj.data))
+	obj.Generation = f.generation
+	obj.TimeCreated = time.Now()
+	f.objects[obj.Name] = &obj
+	json.NewEncoder(w).Encode(&obj)
+}
+
+// matchGeneration reports whether the ifGenerationMatch precondition of r, if
+// any, holds for an object of the generation.
+func matchGeneration(r *http.Request, generation int64) bool {
+	want := r.URL.Query().Get("ifGenerationMatch")
+	return want == "" || want == strconv.FormatInt(generation, 10)
+}
+
+// writeError writes an error response of the JSON API.
+func writeError(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error": {"code": %d, "message": %q}}`, code, http.StatusText(code))
+}
+
+// testSigningKey is a service account key to sign URLs with in tests.
+var testSigningKey = sync.OnceValue(func() *signingKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return &signingKey{
+		email:      "uploader@project.iam.gserviceaccount.com",
+		privateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+	}
+})
+
+// newTestClient returns a client of the fake server with the prefix
+// "prefix/", which signs URLs like against GCS.
+func newTestClient(t *testing.T, f *fakeServer, opts ...Option) *Client {
+	t.Helper()
+	c, err := NewClientWithEndpoint(context.Background(), "test-bucket", "prefix/", "", f.URL+"/storage/v1/", opts...)
+	if err != nil {
+		t.Fatalf("NewClientWithEndpoint() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	// Without an endpoint, SignedURL signs instead of returning emulator URLs.
+	c.endpoint = ""
+	c.signingKey = testSigningKey()
+	return c
+}
@@ -0,0 +1,64 @@
+package gcs
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans and metrics of the client.
+const instrumentationName = "github.com/minodisk/reprint/internal/gcs"
+
+// Operations traced and measured by the client.
+const (
+	opUpload         = "upload"
+	opSignURL        = "sign_url"
+	opDelete         = "delete"
+	opScheduleDelete = "schedule_delete"
+)
+
+// instruments are the metrics recorded by the client.
+type instruments struct {
+	uploaded metric.Int64Counter     // bytes uploaded
+	duration metric.Float64Histogram // latency of operations
+	errors   metric.Int64Counter     // failed operations
+}
+
+// newInstruments creates the metrics of the client. An instrument that
+// cannot be created is a no-op, so the errors are ignored.
+func newInstruments(mp metric.MeterProvider) instruments {
+	m := mp.Meter(instrumentationName)
+	var in instruments
+	in.uploaded, _ = m.Int64Counter("reprint.gcs.upload.size",
+		metric.WithUnit("By"), metric.WithDescription("Bytes uploaded, not counting reused uploads"))
+	in.duration, _ = m.Float64Histogram("reprint.gcs.operation.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of GCS operations"))
+	in.errors, _ = m.Int64Counter("reprint.gcs.operation.errors",
+		metric.WithDescription("Failed GCS operations"))
+	return in
+}
+
+// startSpan starts the span of an operation on an object.
+func (c *Client) startSpan(ctx context.Context, op, objectName string) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "gcs."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("gcs.bucket", c.bucket),
+		attribute.String("gcs.object", objectName),
+	))
+}
+
+// endSpan ends the span of an operation started at start, and records its
+// duration and failure.
+func (c *Client) endSpan(ctx context.Context, span trace.Span, op string, start time.Time, err error) {
+	attrs := metric.WithAttributes(attribute.String("reprint.operation", op), attribute.String("gcs.bucket", c.bucket))
+	c.metrics.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	if err != nil {
+		c.metrics.errors.Add(ctx, 1, attrs)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
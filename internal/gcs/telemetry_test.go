@@ -0,0 +1,113 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useTestTelemetry makes c record spans in the returned recorder and metrics
+// in the returned reader.
+func useTestTelemetry(c *Client) (*tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	spans := tracetest.NewSpanRecorder()
+	metrics := sdkmetric.NewManualReader()
+	c.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)).Tracer(instrumentationName)
+	c.metrics = newInstruments(sdkmetric.NewMeterProvider(sdkmetric.WithReader(metrics)))
+	return spans, metrics
+}
+
+// sums returns the sum of each counter and the count of each histogram.
+func sums(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	got := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					got[m.Name] += dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					got[m.Name] += int64(dp.Count)
+				}
+			}
+		}
+	}
+	return got
+}
+
+// durations returns the total duration of each operation in seconds.
+func durations(t *testing.T, reader *sdkmetric.ManualReader) map[string]float64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	got := map[string]float64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			data, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok || m.Name != "reprint.gcs.operation.duration" {
+				continue
+			}
+			for _, dp := range data.DataPoints {
+				op, _ := dp.Attributes.Value("reprint.operation")
+				got[op.AsString()] += dp.Sum
+			}
+		}
+	}
+	return got
+}
+
+func TestClient_Upload_Telemetry(t *testing.T) {
+	f := newFakeServer(t)
+	c := newTestClient(t, f)
+	spans, metrics := useTestTelemetry(c)
+	content := []byte("image")
+	ctx := context.Background()
+
+	start := time.Now()
+	if _, err := c.Upload(ctx, "file-1", bytes.NewReader(content), "image/png"); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	// The object exists now, so uploading it again fails.
+	if _, err := c.Upload(ctx, "file-1", bytes.NewReader(content), "image/png"); err == nil {
+		t.Fatal("Upload() of an existing object succeeded, want an error")
+	}
+	elapsed := time.Since(start)
+
+	ended := spans.Ended()
+	var names []string
+	for _, s := range ended {
+		names = append(names, s.Name())
+	}
+	if len(ended) != 3 || ended[0].Name() != "gcs.sign_url" || ended[1].Name() != "gcs.upload" || ended[2].Name() != "gcs.upload" {
+		t.Fatalf("spans = %v, want gcs.sign_url in gcs.upload, then a failed gcs.upload", names)
+	}
+	if ended[0].Parent().SpanID() != ended[1].SpanContext().SpanID() {
+		t.Error("gcs.sign_url is not a child of gcs.upload")
+	}
+	if got := ended[2].Status().Code.String(); got != "Error" {
+		t.Errorf("failed upload span status = %s, want Error", got)
+	}
+
+	got := sums(t, metrics)
+	// The failed upload sends its bytes, but only the successful one counts.
+	if got["reprint.gcs.upload.size"] != int64(len(content)) || got["reprint.gcs.operation.errors"] != 1 || got["reprint.gcs.operation.duration"] != 3 {
+		t.Errorf("metrics = %v, want %d bytes uploaded, 1 error and 3 operations", got, len(content))
+	}
+	if d := durations(t, metrics)[opUpload]; d <= 0 || d > elapsed.Seconds() {
+		t.Errorf("upload duration = %vs, want between 0 and %v", d, elapsed)
+	}
+}
@@ -0,0 +1,77 @@
+package gcs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Transports of the GCS client.
+const (
+	TransportHTTP = "http" // JSON API over HTTP (default)
+	TransportGRPC = "grpc" // gRPC API
+)
+
+// ValidateTransport checks that transport is a supported client transport.
+// Empty means the default (HTTP) and is valid.
+func ValidateTransport(transport string) error {
+	switch strings.ToLower(transport) {
+	case "", TransportHTTP, TransportGRPC:
+		return nil
+	}
+	return fmt.Errorf("invalid transport %q: must be %s or %s", transport, TransportHTTP, TransportGRPC)
+}
+
+// grpcEmulatorOptions returns the client options to reach an emulator over
+// plaintext gRPC on the host of its HTTP endpoint.
+func grpcEmulatorOptions(endpoint string) ([]option.ClientOption, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	return []option.ClientOption{
+		option.WithEndpoint(u.Host),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}, nil
+}
+
+// signingKey is a service account key used to sign URLs.
+type signingKey struct {
+	email      string
+	privateKey []byte
+}
+
+// loadSigningKey reads the service account key to sign URLs with from
+// credentialsJSON or, if that is empty, the credentials file. The gRPC client
+// does not keep its credentials like the HTTP client does, so signed URLs
+// need the key passed explicitly. It returns nil if there is no service
+// account key, leaving signing to the storage library.
+func loadSigningKey(credentialsJSON []byte, credentials string) (*signingKey, error) {
+	data := credentialsJSON
+	if len(data) == 0 {
+		if credentials == "" {
+			return nil, nil
+		}
+		var err error
+		if data, err = os.ReadFile(credentials); err != nil {
+			return nil, fmt.Errorf("failed to read credentials: %w", err)
+		}
+	}
+
+	var key struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil || key.Type != "service_account" || key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, nil
+	}
+	return &signingKey{email: key.ClientEmail, privateKey: []byte(key.PrivateKey)}, nil
+}
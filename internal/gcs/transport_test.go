@@ -0,0 +1,66 @@
+package gcs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateTransport(t *testing.T) {
+	tests := []struct {
+		transport string
+		wantErr   bool
+	}{
+		{transport: ""},
+		{transport: "http"},
+		{transport: "grpc"},
+		{transport: "GRPC"},
+		{transport: "http2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.transport, func(t *testing.T) {
+			err := ValidateTransport(tt.transport)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTransport(%q) error = %v, wantErr %v", tt.transport, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	keyJSON := `{"type":"service_account","client_email":"sa@example.iam.gserviceaccount.com","private_key":"KEY"}`
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(keyJSON), 0600); err != nil {
+		t.Fatalf("failed to write credentials: %v", err)
+	}
+
+	for name, key := range map[string]func() (*signingKey, error){
+		"inline": func() (*signingKey, error) { return loadSigningKey([]byte(keyJSON), "") },
+		"file":   func() (*signingKey, error) { return loadSigningKey(nil, path) },
+	} {
+		got, err := key()
+		if err != nil {
+			t.Fatalf("%s: loadSigningKey() error = %v", name, err)
+		}
+		if got == nil || got.email != "sa@example.iam.gserviceaccount.com" || string(got.privateKey) != "KEY" {
+			t.Errorf("%s: loadSigningKey() = %+v", name, got)
+		}
+	}
+
+	got, err := loadSigningKey([]byte(`{"type":"authorized_user"}`), "")
+	if err != nil || got != nil {
+		t.Errorf("loadSigningKey() for user credentials = %+v, %v, want nil", got, err)
+	}
+	if _, err := loadSigningKey(nil, filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loadSigningKey() should fail for a missing file")
+	}
+}
+
+func TestNewClient_InvalidTransport(t *testing.T) {
+	_, err := NewClientWithEndpoint(context.Background(), "test-bucket", "", "", testEndpoint, WithTransport("carrier-pigeon"))
+	if err == nil {
+		t.Error("NewClientWithEndpoint() should fail for an invalid transport")
+	}
+}
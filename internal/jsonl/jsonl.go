@@ -0,0 +1,96 @@
+// Package jsonl stores values as JSON lines in append-only files shared by
+// concurrent reprint processes, which serialize on a lock file next to each
+// file.
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/minodisk/reprint/internal/filelock"
+)
+
+// Lock blocks until it holds the lock of the file at path, exclusively for
+// writers and shared for readers, and returns a function that releases it.
+// It creates the directory of the file if needed.
+func Lock(path string, exclusive bool) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	return filelock.Lock(path+".lock", exclusive)
+}
+
+// Marshal returns values as JSON lines.
+func Marshal[T any](values []T) ([]byte, error) {
+	var buf []byte
+	for _, v := range values {
+		line, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	return buf, nil
+}
+
+// Append appends lines returned by Marshal to the file at path, creating it
+// if needed. The caller holds the exclusive lock.
+func Append(path string, lines []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(lines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Replace atomically replaces the file at path with values. The caller holds
+// the exclusive lock.
+func Replace[T any](path string, values []T) error {
+	buf, err := Marshal(values)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Read calls fn with the values of the file at path in order. A missing file
+// has no values. The caller holds the lock.
+func Read[T any](path string, fn func(T)) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var v T
+		// A line cut short by a crash is skipped rather than failing the read.
+		if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+			continue
+		}
+		fn(v)
+	}
+	return sc.Err()
+}
@@ -0,0 +1,76 @@
+package jsonl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type value struct {
+	N int `json:"n"`
+}
+
+// readAll returns the values of the file at path.
+func readAll(t *testing.T, path string) []value {
+	t.Helper()
+	var values []value
+	if err := Read(path, func(v value) { values = append(values, v) }); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	return values
+}
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "values.jsonl")
+	unlock, err := Lock(path, true)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	defer unlock()
+
+	if got := readAll(t, path); len(got) != 0 {
+		t.Errorf("Read() of a missing file = %v, want none", got)
+	}
+	for _, values := range [][]value{{{1}, {2}}, {{3}}} {
+		lines, err := Marshal(values)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if err := Append(path, lines); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if got := fmt.Sprint(readAll(t, path)); got != "[{1} {2} {3}]" {
+		t.Errorf("Read() = %s, want [{1} {2} {3}]", got)
+	}
+}
+
+func TestRead_SkipsTruncatedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.jsonl")
+	// A crash in the middle of a write leaves a partial line.
+	if err := os.WriteFile(path, []byte("{\"n\":1}\n{\"n\":2}\n{\"n\""), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(readAll(t, path)); got != "[{1} {2}]" {
+		t.Errorf("Read() = %s, want [{1} {2}]", got)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "values.jsonl")
+	if err := os.WriteFile(path, []byte("{\"n\":1}\n{\"n\":2}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(path, []value{{3}}); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if got := fmt.Sprint(readAll(t, path)); got != "[{3}]" {
+		t.Errorf("Read() = %s, want [{3}]", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Replace() left %d files, want 1", len(entries))
+	}
+}
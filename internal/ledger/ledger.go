@@ -0,0 +1,166 @@
+// Package ledger records uploaded objects in a local append-only file, so
+// that objects left behind by crashed runs can be found and deleted later.
+package ledger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/minodisk/reprint/internal/jsonl"
+)
+
+// Operations of an Entry.
+const (
+	OpUpload = "upload" // an object was uploaded
+	OpDelete = "delete" // an object was deleted or found to be gone
+	// OpSchedule marks an object as scheduled for deletion at DeleteAt. It
+	// stays outstanding, since the scheduled delete may never run.
+	OpSchedule = "schedule"
+)
+
+// Entry is a line of the ledger.
+type Entry struct {
+	Op     string    `json:"op"`
+	Bucket string    `json:"bucket"`
+	Prefix string    `json:"prefix,omitempty"`
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	// Generation is the generation of an uploaded object.
+	Generation int64 `json:"generation,omitempty"`
+	// PID is the run that uploaded the object, as returned by RunPID.
+	PID int `json:"pid,omitempty"`
+	// Session is the session of an uploaded object, if any.
+	Session string `json:"session,omitempty"`
+	// Profile and BillingProject are the settings an object was uploaded
+	// with, which deleting it needs as well.
+	Profile        string `json:"profile,omitempty"`
+	BillingProject string `json:"billing_project,omitempty"`
+	// DeleteAt is when an object is scheduled to be deleted, if it is.
+	DeleteAt time.Time `json:"delete_at,omitzero"`
+}
+
+// Running reports whether the process that recorded the entry is still
+// running, so its uploads may still be in use.
+func (e Entry) Running() bool {
+	return e.PID > 0 && processRunning(e.PID)
+}
+
+// key identifies the object of an entry.
+func (e Entry) key() string {
+	return e.Bucket + "\x00" + e.Prefix + e.ID
+}
+
+// Ledger is an append-only file of entries. Its methods may be called
+// concurrently from several processes; they serialize on a lock file next to
+// the ledger.
+type Ledger struct {
+	path string
+}
+
+// New returns the ledger stored at path. The file is created on first write.
+func New(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// Path returns the path of the ledger file.
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Append adds entries to the ledger.
+func (l *Ledger) Append(entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	buf, err := jsonl.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := l.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := jsonl.Append(l.path, buf); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	return nil
+}
+
+// Outstanding returns the uploads that have not been deleted, oldest first.
+func (l *Ledger) Outstanding() ([]Entry, error) {
+	unlock, err := l.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return l.outstanding()
+}
+
+// Compact rewrites the ledger to hold only the outstanding uploads.
+func (l *Ledger) Compact() error {
+	unlock, err := l.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := l.outstanding()
+	if err != nil {
+		return err
+	}
+	if err := jsonl.Replace(l.path, entries); err != nil {
+		return fmt.Errorf("failed to compact ledger: %w", err)
+	}
+	return nil
+}
+
+// outstanding replays the ledger. The caller holds the lock.
+func (l *Ledger) outstanding() ([]Entry, error) {
+	var uploads []Entry
+	index := make(map[string]int)
+	err := jsonl.Read(l.path, func(e Entry) {
+		switch e.Op {
+		case OpUpload:
+			// A reused object (e.g., from the upload cache) now belongs to
+			// the later upload.
+			if i, ok := index[e.key()]; ok {
+				uploads[i].Op = ""
+			}
+			index[e.key()] = len(uploads)
+			uploads = append(uploads, e)
+		case OpSchedule:
+			if i, ok := index[e.key()]; ok {
+				uploads[i].DeleteAt = e.DeleteAt
+			}
+		case OpDelete:
+			if i, ok := index[e.key()]; ok {
+				uploads[i].Op = ""
+				delete(index, e.key())
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+
+	var entries []Entry
+	for _, e := range uploads {
+		if e.Op == OpUpload {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// lock takes the lock of the ledger, exclusively for writes, and returns a
+// function that releases it.
+func (l *Ledger) lock(exclusive bool) (func(), error) {
+	unlock, err := jsonl.Lock(l.path, exclusive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock ledger: %w", err)
+	}
+	return unlock, nil
+}
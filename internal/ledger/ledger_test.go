@@ -0,0 +1,217 @@
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func upload(id string) Entry {
+	return Entry{Op: OpUpload, Bucket: "bucket", Prefix: "deck/", ID: id, Time: time.Now(), PID: 1}
+}
+
+func deleted(id string) Entry {
+	return Entry{Op: OpDelete, Bucket: "bucket", Prefix: "deck/", ID: id, Time: time.Now()}
+}
+
+func ids(entries []Entry) []string {
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestLedger_Outstanding(t *testing.T) {
+	l := New(filepath.Join(t.TempDir(), "state", "ledger.jsonl"))
+
+	if got, err := l.Outstanding(); err != nil || len(got) != 0 {
+		t.Fatalf("Outstanding() of a missing ledger = %v, %v, want none", got, err)
+	}
+
+	other := upload("b")
+	other.Bucket = "other-bucket"
+	if err := l.Append(upload("a"), upload("b"), other, upload("c")); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	// Deletes of unknown objects and other buckets are ignored.
+	if err := l.Append(deleted("b"), deleted("x")); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	// An object uploaded again by another run moves to that run.
+	reused := upload("a")
+	reused.PID = 2
+	if err := l.Append(reused); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	got, err := l.Outstanding()
+	if err != nil {
+		t.Fatalf("Outstanding() error = %v", err)
+	}
+	if fmt.Sprint(ids(got)) != "[b c a]" || got[0].Bucket != "other-bucket" || got[2].PID != 2 {
+		t.Errorf("Outstanding() = %+v, want b in other-bucket, c, and a of the second run", got)
+	}
+}
+
+func TestLedger_Scheduled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	l := New(path)
+	at := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	scheduled := Entry{Op: OpSchedule, Bucket: "bucket", Prefix: "deck/", ID: "a", Time: time.Now(), DeleteAt: at}
+	if err := l.Append(upload("a"), upload("b"), scheduled); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	// A scheduled object is outstanding until it is deleted, also after
+	// compaction.
+	if err := l.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	got, err := l.Outstanding()
+	if err != nil {
+		t.Fatalf("Outstanding() error = %v", err)
+	}
+	if fmt.Sprint(ids(got)) != "[a b]" || !got[0].DeleteAt.Equal(at) || !got[1].DeleteAt.IsZero() {
+		t.Errorf("Outstanding() = %+v, want a scheduled at %v and b", got, at)
+	}
+
+	if err := l.Append(deleted("a")); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if got, _ := l.Outstanding(); fmt.Sprint(ids(got)) != "[b]" {
+		t.Errorf("Outstanding() = %v, want [b]", ids(got))
+	}
+}
+
+func TestLedger_SkipsTruncatedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	l := New(path)
+	if err := l.Append(upload("a")); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	// A crash in the middle of a write leaves a partial line.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"upload","bucket":"bu`)
+	f.Close()
+
+	got, err := l.Outstanding()
+	if err != nil {
+		t.Fatalf("Outstanding() error = %v", err)
+	}
+	if fmt.Sprint(ids(got)) != "[a]" {
+		t.Errorf("Outstanding() = %v, want [a]", ids(got))
+	}
+}
+
+func TestLedger_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	l := New(path)
+	if err := l.Append(upload("a"), upload("b"), deleted("a")); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	if err := l.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 1 {
+		t.Errorf("ledger has %d lines after compaction, want 1:\n%s", lines, data)
+	}
+	if got, _ := l.Outstanding(); fmt.Sprint(ids(got)) != "[b]" {
+		t.Errorf("Outstanding() = %v, want [b]", ids(got))
+	}
+}
+
+func TestLedger_ConcurrentAppends(t *testing.T) {
+	l := New(filepath.Join(t.TempDir(), "ledger.jsonl"))
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Append(upload(fmt.Sprint(i))); err != nil {
+				t.Errorf("Append() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := l.Outstanding()
+	if err != nil {
+		t.Fatalf("Outstanding() error = %v", err)
+	}
+	if len(got) != n {
+		t.Errorf("Outstanding() has %d entries, want %d", len(got), n)
+	}
+}
+
+func TestEntry_Running(t *testing.T) {
+	if !(Entry{PID: os.Getpid()}).Running() {
+		t.Error("Running() = false for this process")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run a process: %v", err)
+	}
+	if (Entry{PID: cmd.Process.Pid}).Running() {
+		t.Error("Running() = true for an exited process")
+	}
+	if (Entry{}).Running() {
+		t.Error("Running() = true without a PID")
+	}
+}
+
+func TestRunPID(t *testing.T) {
+	if os.Getenv("REPRINT_TEST_RUN_PID") != "" {
+		fmt.Println(RunPID())
+		return
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	// The trailing command keeps sh from replacing itself with the test.
+	cmd := exec.Command(sh, "-c", fmt.Sprintf("%q -test.run='^TestRunPID$'; true", os.Args[0]))
+	cmd.Env = append(os.Environ(), "REPRINT_TEST_RUN_PID=1")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("failed to run a process: %v", err)
+	}
+	// The PID is followed by the output of the test binary.
+	got, _, _ := bytes.Cut(out, []byte("\n"))
+	if want := fmt.Sprint(os.Getpid()); string(got) != want {
+		t.Errorf("RunPID() under sh = %s, want %s", got, want)
+	}
+}
+
+func TestIsShell(t *testing.T) {
+	for name, want := range map[string]bool{
+		"sh":             true,
+		"-bash":          true,
+		"/usr/bin/zsh":   true,
+		"PowerShell.exe": true,
+		"deck":           false,
+		"deck.exe":       false,
+	} {
+		if got := isShell(name); got != want {
+			t.Errorf("isShell(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
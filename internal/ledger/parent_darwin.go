@@ -0,0 +1,17 @@
+package ledger
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// processParent returns the parent PID and the name of the process with pid.
+func processParent(pid int) (int, string, error) {
+	kp, err := unix.SysctlKinfoProc("kern.proc.pid", pid)
+	if err != nil {
+		return 0, "", err
+	}
+	name, _, _ := bytes.Cut(kp.Proc.P_comm[:], []byte{0})
+	return int(kp.Eproc.Ppid), string(name), nil
+}
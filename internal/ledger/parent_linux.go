@@ -0,0 +1,31 @@
+package ledger
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processParent returns the parent PID and the name of the process with pid.
+func processParent(pid int) (int, string, error) {
+	// The name is in parentheses and may contain spaces, so the fields after
+	// it are found from the last closing parenthesis.
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, "", err
+	}
+	open, end := strings.IndexByte(string(stat), '('), strings.LastIndexByte(string(stat), ')')
+	if open < 0 || end < open {
+		return 0, "", fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 2 {
+		return 0, "", fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, "", fmt.Errorf("malformed /proc/%d/stat: %w", pid, err)
+	}
+	return ppid, string(stat[open+1 : end]), nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+
+package ledger
+
+import "errors"
+
+// processParent is not supported on this platform, so RunPID returns the
+// parent process.
+func processParent(pid int) (int, string, error) {
+	return 0, "", errors.ErrUnsupported
+}
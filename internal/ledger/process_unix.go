@@ -0,0 +1,11 @@
+//go:build unix
+
+package ledger
+
+import "syscall"
+
+// processRunning reports whether a process with pid exists.
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,42 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxShells bounds the walk up the process tree in RunPID.
+const maxShells = 8
+
+// shells are the names of shells that run commands on behalf of another
+// process, e.g., deck's hooks.
+var shells = map[string]bool{
+	"sh": true, "bash": true, "dash": true, "zsh": true, "ksh": true, "mksh": true, "fish": true, "busybox": true,
+	"cmd": true, "powershell": true, "pwsh": true,
+}
+
+// RunPID returns the PID of the run that invoked the current process: its
+// parent, or, if the parent is a shell like the `sh -c` that deck runs hooks
+// with, the first ancestor that is not a shell. The shell exits with the hook,
+// while the deck run lasts until all of its hooks are done.
+func RunPID() int {
+	pid := os.Getppid()
+	for range maxShells {
+		parent, name, err := processParent(pid)
+		if err != nil || parent <= 1 || !isShell(name) {
+			break
+		}
+		pid = parent
+	}
+	return pid
+}
+
+// isShell reports whether name is the executable name of a shell.
+func isShell(name string) bool {
+	name = strings.ToLower(filepath.Base(name))
+	name = strings.TrimSuffix(name, ".exe")
+	// Login shells are named with a leading dash.
+	name = strings.TrimPrefix(name, "-")
+	return shells[name]
+}
@@ -0,0 +1,109 @@
+// Package telemetry sets up OpenTelemetry tracing and metrics for reprint
+// CLIs, exporting over OTLP or to a local file.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Options configure Setup.
+type Options struct {
+	ServiceName    string
+	ServiceVersion string
+	// File receives spans and metrics as JSON lines when no OTLP endpoint is
+	// configured. Empty disables telemetry in that case.
+	File string
+}
+
+// Setup installs global tracer and meter providers that export over OTLP/HTTP
+// if an OTLP endpoint is configured in the environment, or to opts.File
+// otherwise. It returns a function that flushes and stops the providers. If
+// neither is configured, the global providers stay no-ops.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	var (
+		spans   sdktrace.SpanExporter
+		metrics sdkmetric.Exporter
+		closers []func() error
+		err     error
+	)
+	switch {
+	case OTLPConfigured():
+		if spans, err = otlptracehttp.New(ctx); err != nil {
+			return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+		}
+		if metrics, err = otlpmetrichttp.New(ctx); err != nil {
+			return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+		}
+	case opts.File != "":
+		f, err := os.OpenFile(opts.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open telemetry file: %w", err)
+		}
+		closers = append(closers, f.Close)
+		// Neither exporter fails without options that can fail.
+		spans, _ = stdouttrace.New(stdouttrace.WithWriter(f))
+		metrics, _ = stdoutmetric.New(stdoutmetric.WithWriter(f))
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Attributes from OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override
+	// the defaults.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(opts.ServiceName), semconv.ServiceVersion(opts.ServiceVersion)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telemetry resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(spans), sdktrace.WithResource(res))
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metrics)), sdkmetric.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		errs := []error{tp.Shutdown(ctx), mp.Shutdown(ctx)}
+		for _, c := range closers {
+			errs = append(errs, c())
+		}
+		return errors.Join(errs...)
+	}, nil
+}
+
+// OTLPConfigured reports whether the environment configures an OTLP endpoint.
+func OTLPConfigured() bool {
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ContextFromEnv returns ctx with the trace context that a parent process
+// passed in the TRACEPARENT and TRACESTATE environment variables, so that
+// spans join the trace of the pipeline running the CLI.
+func ContextFromEnv(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
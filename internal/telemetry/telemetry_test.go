@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup_File(t *testing.T) {
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"} {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+
+	shutdown, err := Setup(context.Background(), Options{ServiceName: "reprint-test", ServiceVersion: "v1.2.3", File: path})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+	counter, _ := otel.Meter("test").Int64Counter("test.counter")
+	counter.Add(context.Background(), 3)
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"Name":"test-span"`, `"Name":"test.counter"`, `"Value":"reprint-test"`, `"Value":"v1.2.3"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("telemetry file does not contain %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "")
+
+	shutdown, err := Setup(context.Background(), Options{ServiceName: "reprint-test"})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+}
+
+func TestContextFromEnv(t *testing.T) {
+	t.Setenv("TRACEPARENT", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	t.Setenv("TRACESTATE", "")
+
+	sc := trace.SpanContextFromContext(ContextFromEnv(context.Background()))
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID().String() != "00f067aa0ba902b7" || !sc.IsRemote() {
+		t.Errorf("ContextFromEnv() span context = %+v, want the parent from TRACEPARENT", sc)
+	}
+
+	t.Setenv("TRACEPARENT", "")
+	if sc := trace.SpanContextFromContext(ContextFromEnv(context.Background())); sc.IsValid() {
+		t.Errorf("ContextFromEnv() without TRACEPARENT = %+v, want no span context", sc)
+	}
+}